@@ -1,19 +1,21 @@
 package aws_s3
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
-	"strings"
+	"net/http"
+	"net/url"
+	"path/filepath"
 	"time"
 
 	"github.com/CPU-commits/Intranet_BFiles/settings"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
-	"github.com/google/uuid"
 )
 
 type AWSS3 struct {
@@ -22,6 +24,20 @@ type AWSS3 struct {
 
 var settingsData = settings.GetSettings()
 
+var ErrNotFound = errors.New("el archivo no existe en el almacenamiento")
+
+// Missing keys are returned as ErrNotFound, so they are told apart
+// from the failures of S3
+func mapError(err error) error {
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
+		return ErrNotFound
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
 func NewAWSS3() *AWSS3 {
 	sess := session.Must(session.NewSession(&aws.Config{
 		Region: aws.String(settingsData.AWS_REGION),
@@ -45,6 +61,60 @@ func (aws_s3 *AWSS3) GetFileToken(key string) (string, error) {
 	return urlStr, nil
 }
 
+// Presigned PUT, the client must send the same type and size
+func (aws_s3 *AWSS3) GetUploadToken(key, contentType string, size int64) (string, error) {
+	svc := s3.New(aws_s3.sess)
+
+	req, _ := svc.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:        aws.String(settingsData.AWS_BUCKET),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	})
+	urlStr, err := req.Presign(15 * time.Minute)
+	if err != nil {
+		return "", err
+	}
+	return urlStr, nil
+}
+
+func (aws_s3 *AWSS3) HeadFile(key string) (int64, string, error) {
+	svc := s3.New(aws_s3.sess)
+	out, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(settingsData.AWS_BUCKET),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, "", mapError(err)
+	}
+	return aws.Int64Value(out.ContentLength), aws.StringValue(out.ContentType), nil
+}
+
+func (aws_s3 *AWSS3) GetFile(key string) (io.ReadCloser, error) {
+	svc := s3.New(aws_s3.sess)
+	out, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(settingsData.AWS_BUCKET),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return out.Body, nil
+}
+
+func (aws_s3 *AWSS3) GetFileRange(key string, offset, length int64) (io.ReadCloser, error) {
+	svc := s3.New(aws_s3.sess)
+	out, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(settingsData.AWS_BUCKET),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return out.Body, nil
+}
+
 func (aws_s3 *AWSS3) DeleteFile(key string) error {
 	svc := s3.New(aws_s3.sess)
 	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
@@ -64,24 +134,126 @@ func (aws_s3 *AWSS3) DeleteFile(key string) error {
 	return nil
 }
 
-func (aws_s3 *AWSS3) UploadFile(file *multipart.FileHeader, idUser string) (*s3manager.UploadOutput, string, error) {
-	ext := strings.Split(file.Filename, ".")
+func (aws_s3 *AWSS3) UploadFile(file io.Reader, filename, idUser string) (string, string, error) {
+	// The uploader reads the body by parts, so the file is never
+	// held in memory completely
+	uploader := s3manager.NewUploader(aws_s3.sess, func(u *s3manager.Uploader) {
+		u.PartSize = s3manager.MinUploadPartSize
+		u.Concurrency = 2
+	})
+	key := utils.NewFileKey(idUser, filename)
+	result, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(settingsData.AWS_BUCKET),
+		Key:    aws.String(key),
+		Body:   file,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return result.Location, key, nil
+}
+
+func (aws_s3 *AWSS3) PutFile(key string, file io.Reader) (string, error) {
 	uploader := s3manager.NewUploader(aws_s3.sess)
-	// To buffer
-	openFile, err := file.Open()
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(settingsData.AWS_BUCKET),
+		Key:    aws.String(key),
+		Body:   file,
+	}
+	if contentType := utils.GetMimeType(filepath.Ext(key)); contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	result, err := uploader.Upload(input)
 	if err != nil {
-		return nil, "", err
+		return "", err
+	}
+	return result.Location, nil
+}
+
+// Copy the object inside the bucket, the copy gets the type of its
+// extension
+func (aws_s3 *AWSS3) CopyFile(src, dst string) (string, error) {
+	svc := s3.New(aws_s3.sess)
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(settingsData.AWS_BUCKET),
+		Key:               aws.String(dst),
+		CopySource:        aws.String(url.PathEscape(settingsData.AWS_BUCKET + "/" + src)),
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
 	}
-	buf := bytes.NewBuffer(nil)
-	if _, err := io.Copy(buf, openFile); err != nil {
-		return nil, "", err
+	if contentType := utils.GetMimeType(filepath.Ext(dst)); contentType != "" {
+		input.ContentType = aws.String(contentType)
 	}
-	fileName := uuid.New()
-	key := fmt.Sprintf("user_files/%s/%s.%s", idUser, fileName.String(), ext[len(ext)-1])
-	result, err := uploader.Upload(&s3manager.UploadInput{
+	if _, err := svc.CopyObject(input); err != nil {
+		return "", mapError(err)
+	}
+	// Same location the uploader returns for the key
+	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(settingsData.AWS_BUCKET),
+		Key:    aws.String(dst),
+	})
+	if err := req.Build(); err != nil {
+		return "", err
+	}
+	return req.HTTPRequest.URL.String(), nil
+}
+
+func (aws_s3 *AWSS3) CreateMultipartUpload(filename, idUser string) (string, string, error) {
+	svc := s3.New(aws_s3.sess)
+	key := utils.NewFileKey(idUser, filename)
+	out, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
 		Bucket: aws.String(settingsData.AWS_BUCKET),
 		Key:    aws.String(key),
-		Body:   buf,
 	})
-	return result, key, err
+	if err != nil {
+		return "", "", err
+	}
+	return key, *out.UploadId, nil
+}
+
+func (aws_s3 *AWSS3) UploadPart(key, uploadID string, partNumber int, part io.ReadSeeker) (string, error) {
+	svc := s3.New(aws_s3.sess)
+	out, err := svc.UploadPart(&s3.UploadPartInput{
+		Bucket:     aws.String(settingsData.AWS_BUCKET),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(int64(partNumber)),
+		Body:       part,
+	})
+	if err != nil {
+		return "", err
+	}
+	return *out.ETag, nil
+}
+
+func (aws_s3 *AWSS3) CompleteMultipartUpload(key, uploadID string, etags []string) (string, error) {
+	svc := s3.New(aws_s3.sess)
+	parts := make([]*s3.CompletedPart, len(etags))
+	for i, etag := range etags {
+		parts[i] = &s3.CompletedPart{
+			ETag:       aws.String(etag),
+			PartNumber: aws.Int64(int64(i + 1)),
+		}
+	}
+	out, err := svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(settingsData.AWS_BUCKET),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{
+			Parts: parts,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return *out.Location, nil
+}
+
+func (aws_s3 *AWSS3) AbortMultipartUpload(key, uploadID string) error {
+	svc := s3.New(aws_s3.sess)
+	_, err := svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(settingsData.AWS_BUCKET),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	return err
 }
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func (u *UploadsController) InitDirectUpload(c *gin.Context) {
+	var uploadData forms.DirectUploadForm
+	if err := c.BindJSON(&uploadData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	limits := c.MustGet("file_limits").(*utils.FileLimits)
+	claims, _ := services.NewClaimsFromContext(c)
+
+	slot, err := uploadsService.InitDirectUpload(uploadData, limits, claims)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, &res.Response{
+		Success: true,
+		Data: res.WrapDirectUploadRes(
+			*slot.Upload,
+			slot.URL,
+			slot.Method,
+			slot.Headers,
+		),
+	})
+}
+
+func (u *UploadsController) ConfirmDirectUpload(c *gin.Context) {
+	idUpload := c.Param("idUpload")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	file, err := uploadsService.ConfirmDirectUpload(idUpload, claims)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, &res.Response{
+		Success: true,
+		Data:    res.WrapFileRes(*file),
+	})
+}
+
+func (u *UploadsController) AbortDirectUpload(c *gin.Context) {
+	idUpload := c.Param("idUpload")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	err := uploadsService.AbortDirectUpload(idUpload, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+	})
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Stream the file through the service, supports Range requests for
+// video seeking and If-None-Match with the ETag of the content
+func (f *FilesController) DownloadFile(c *gin.Context) {
+	idFile := c.Param("idFile")
+	variant := c.Query("variant")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	download, err := filesService.DownloadFile(idFile, variant, claims)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Message: err.Err.Error(),
+			Success: false,
+		})
+		return
+	}
+	defer download.Content.Close()
+
+	disposition := "attachment"
+	if c.Query("inline") == "true" && isInlineType(download.Type) {
+		disposition = "inline"
+	}
+	contentDisposition := mime.FormatMediaType(disposition, map[string]string{
+		"filename": download.Filename,
+	})
+	if contentDisposition == "" {
+		contentDisposition = disposition
+	}
+	c.Header("Content-Type", download.Type)
+	c.Header("Content-Disposition", contentDisposition)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("ETag", download.ETag)
+	c.Header("Cache-Control", "private")
+
+	http.ServeContent(c.Writer, c.Request, download.Filename, download.ModTime, download.Content)
+}
+
+// Stream a zip with the files given or with the files of the user
+// that match the filters, nothing is buffered
+func (f *FilesController) DownloadZip(c *gin.Context) {
+	var zipData forms.ZipForm
+	if err := c.BindJSON(&zipData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	claims, _ := services.NewClaimsFromContext(c)
+
+	entries, skipped, err := filesService.GetZipEntries(zipData, claims)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Message: err.Err.Error(),
+			Success: false,
+		})
+		return
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "archivos.zip",
+	}))
+	c.Header("Cache-Control", "private")
+	// Files left out, listed in services.ZIP_SKIPPED_NAME
+	c.Header("X-Skipped-Files", strconv.Itoa(len(skipped)))
+	c.Status(http.StatusOK)
+	// The headers are already sent, the download is cut
+	if err := filesService.WriteZip(c.Writer, entries, skipped); err != nil {
+		log.Printf("zip download: %v", err)
+		c.Abort()
+	}
+}
@@ -1,21 +1,58 @@
 package controllers
 
 import (
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 
 	"github.com/CPU-commits/Intranet_BFiles/forms"
 	"github.com/CPU-commits/Intranet_BFiles/res"
 	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
+// Max size of the text fields read from a streamed multipart body
+const MAX_FIELD_SIZE = 1024
+
+// Text fields of an upload, they must be sent before the file
+func isUploadField(name string) bool {
+	return name == "title" || name == "folder" || name == "rename"
+}
+
+// Name of the first text field left in the body, if any
+func fieldAfterFile(reader *multipart.Reader) string {
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return ""
+		}
+		if isUploadField(part.FormName()) {
+			return part.FormName()
+		}
+	}
+}
+
 // Services
 var filesService = services.NewFilesService()
 
 type FilesController struct{}
 
 func (f *FilesController) GetFiles(c *gin.Context) {
-	permissions := c.DefaultQuery("permissions", "any")
+	var query forms.FilesQueryForm
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	if query.Permissions == "" {
+		query.Permissions = "any"
+	}
+	permissions := query.Permissions
 	if permissions != "private" && permissions != "public" && permissions != "public_classroom" && permissions != "any" {
 		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
 			Success: false,
@@ -25,7 +62,7 @@ func (f *FilesController) GetFiles(c *gin.Context) {
 	}
 
 	claims, _ := services.NewClaimsFromContext(c)
-	files, err := filesService.GetFiles(permissions, claims.ID)
+	files, total, err := filesService.GetFiles(query, claims.ID)
 	if err != nil {
 		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
 			Message: err.Err.Error(),
@@ -33,18 +70,30 @@ func (f *FilesController) GetFiles(c *gin.Context) {
 		})
 		return
 	}
+	// Response, the list alone when the page is not requested
+	if query.Page == 0 && query.Limit == 0 {
+		c.JSON(200, &res.Response{
+			Success: true,
+			Data:    res.WrapOwnFilesRes(files),
+		})
+		return
+	}
+	response := make(map[string]interface{})
+	response["files"] = res.WrapOwnFilesRes(files)
+	response["total"] = total
 
 	c.JSON(200, &res.Response{
 		Success: true,
-		Data:    res.WrapFilesRes(files),
+		Data:    response,
 	})
 }
 
 func (f *FilesController) GetFile(c *gin.Context) {
 	idFile := c.Param("idFile")
+	variant := c.Query("variant")
 	claims, _ := services.NewClaimsFromContext(c)
 
-	file, err := filesService.GetFile(idFile, claims.ID)
+	file, err := filesService.GetFile(idFile, variant, claims)
 	if err != nil {
 		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
 			Message: err.Err.Error(),
@@ -63,37 +112,114 @@ func (f *FilesController) GetFile(c *gin.Context) {
 }
 
 func (f *FilesController) UploadFile(c *gin.Context) {
-	var fileData forms.FileForm
-	if err := c.ShouldBind(&fileData); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
-			Message: err.Error(),
-			Success: false,
-		})
-		return
-	}
-	// Get file from form
-	file, err := c.FormFile("file")
+	// The body is read as a stream, the title, folder and rename
+	// fields must be sent before the file, else the upload fails
+	// with 400
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Message: "Body must be a multipart/form-data",
 			Success: false,
-			Message: "Ha ocurrido un error tratando de leer el archivo",
 		})
 		return
 	}
+	limits := c.MustGet("file_limits").(*utils.FileLimits)
 	claims, _ := services.NewClaimsFromContext(c)
-	// Upload file
-	newFile, errRes := filesService.UploadFile(fileData, file, claims.ID)
-	if errRes != nil {
-		c.AbortWithStatusJSON(errRes.StatusCode, &res.Response{
-			Success: false,
-			Message: errRes.Err.Error(),
-		})
-		return
-	}
 
-	c.JSON(201, &res.Response{
-		Success: true,
-		Data:    res.WrapFileRes(*newFile),
+	var fileData forms.FileForm
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+				Success: false,
+				Message: "Ha ocurrido un error tratando de leer el archivo",
+			})
+			return
+		}
+		switch part.FormName() {
+		case "title":
+			title, err := io.ReadAll(io.LimitReader(part, MAX_FIELD_SIZE))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+					Success: false,
+					Message: "Ha ocurrido un error tratando de leer el título",
+				})
+				return
+			}
+			fileData.Title = string(title)
+		case "folder":
+			folder, err := io.ReadAll(io.LimitReader(part, MAX_FIELD_SIZE))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+					Success: false,
+					Message: "Ha ocurrido un error tratando de leer la carpeta",
+				})
+				return
+			}
+			fileData.Folder = string(folder)
+		case "rename":
+			rename, err := io.ReadAll(io.LimitReader(part, MAX_FIELD_SIZE))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+					Success: false,
+					Message: "Ha ocurrido un error tratando de leer el campo rename",
+				})
+				return
+			}
+			fileData.Rename = string(rename) == "true"
+		case "file":
+			if fileData.Title == "" {
+				c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+					Message: "El título debe enviarse antes del archivo",
+					Success: false,
+				})
+				return
+			}
+			if err := binding.Validator.ValidateStruct(&fileData); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+					Message: err.Error(),
+					Success: false,
+				})
+				return
+			}
+			// Upload file
+			newFile, errRes := filesService.UploadFile(
+				fileData,
+				part.FileName(),
+				part,
+				limits,
+				claims,
+			)
+			if errRes != nil {
+				c.AbortWithStatusJSON(errRes.StatusCode, &res.Response{
+					Success: false,
+					Message: errRes.Err.Error(),
+				})
+				return
+			}
+			// The file was saved without the fields sent after it
+			if field := fieldAfterFile(reader); field != "" {
+				filesService.DiscardFile(newFile)
+				c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+					Success: false,
+					Message: fmt.Sprintf("El campo %s debe enviarse antes del archivo", field),
+				})
+				return
+			}
+
+			c.JSON(201, &res.Response{
+				Success: true,
+				Data:    res.WrapFileRes(*newFile),
+			})
+			return
+		}
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+		Success: false,
+		Message: "Ha ocurrido un error tratando de leer el archivo",
 	})
 }
 
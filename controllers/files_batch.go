@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Upload several files in one body. Every "title" field applies to
+// the next "file", "folder" and "rename" to all the files after it, so
+// a field after the last file is rejected. The files
+// are spooled to disk while reading and uploaded concurrently, the
+// response has the result of each one
+func (f *FilesController) UploadFiles(c *gin.Context) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Message: "Body must be a multipart/form-data",
+			Success: false,
+		})
+		return
+	}
+	limits := c.MustGet("file_limits").(*utils.FileLimits)
+	claims, _ := services.NewClaimsFromContext(c)
+
+	var batch []services.BatchFile
+	var spooled []*os.File
+	defer func() {
+		for _, file := range spooled {
+			utils.RemoveSpooled(file)
+		}
+	}()
+	var fileData forms.FileForm
+	// Field read since the last file
+	var lastField string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+				Success: false,
+				Message: "Ha ocurrido un error tratando de leer los archivos",
+			})
+			return
+		}
+		if isUploadField(part.FormName()) {
+			lastField = part.FormName()
+		}
+		switch part.FormName() {
+		case "title":
+			title, err := io.ReadAll(io.LimitReader(part, MAX_FIELD_SIZE))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+					Success: false,
+					Message: "Ha ocurrido un error tratando de leer el título",
+				})
+				return
+			}
+			fileData.Title = string(title)
+		case "folder":
+			folder, err := io.ReadAll(io.LimitReader(part, MAX_FIELD_SIZE))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+					Success: false,
+					Message: "Ha ocurrido un error tratando de leer la carpeta",
+				})
+				return
+			}
+			fileData.Folder = string(folder)
+		case "rename":
+			rename, err := io.ReadAll(io.LimitReader(part, MAX_FIELD_SIZE))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+					Success: false,
+					Message: "Ha ocurrido un error tratando de leer el campo rename",
+				})
+				return
+			}
+			fileData.Rename = string(rename) == "true"
+		case "file", "files":
+			if len(batch) == limits.MaxFiles {
+				c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+					Success: false,
+					Message: "Se ha excedido el máximo de archivos por subida",
+				})
+				return
+			}
+			// One byte over the limit is kept so the upload fails
+			// with the size error
+			content, err := utils.SpoolFile(part, limits.MaxSize+1)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+					Success: false,
+					Message: "Ha ocurrido un error tratando de leer el archivo",
+				})
+				return
+			}
+			spooled = append(spooled, content)
+			batch = append(batch, services.BatchFile{
+				Data:     fileData,
+				Filename: part.FileName(),
+				Content:  content,
+			})
+			fileData.Title = ""
+			lastField = ""
+		}
+	}
+	if len(batch) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+			Success: false,
+			Message: "No se ha enviado ningún archivo",
+		})
+		return
+	}
+
+	if lastField != "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+			Success: false,
+			Message: fmt.Sprintf("El campo %s debe enviarse antes de su archivo", lastField),
+		})
+		return
+	}
+
+	results := filesService.UploadFiles(batch, limits, claims)
+	// Response
+	var uploaded int
+	response := make([]*res.BatchFileRes, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			response[i] = res.WrapBatchFileRes(
+				result.Filename,
+				nil,
+				result.Err.StatusCode,
+				result.Err.Err.Error(),
+			)
+			continue
+		}
+		uploaded++
+		response[i] = res.WrapBatchFileRes(
+			result.Filename,
+			result.File,
+			http.StatusCreated,
+			"",
+		)
+	}
+	statusCode := http.StatusCreated
+	if uploaded == 0 {
+		statusCode = http.StatusBadRequest
+	} else if uploaded < len(results) {
+		statusCode = http.StatusMultiStatus
+	}
+	c.JSON(statusCode, &res.Response{
+		Success: uploaded > 0,
+		Data:    response,
+	})
+}
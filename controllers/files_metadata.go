@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/gin-gonic/gin"
+)
+
+func (f *FilesController) UpdateFile(c *gin.Context) {
+	var fileData forms.UpdateFileForm
+	if err := c.BindJSON(&fileData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	idFile := c.Param("idFile")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	file, err := filesService.UpdateFile(idFile, fileData, claims)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    res.WrapFileRes(*file),
+	})
+}
+
+func (f *FilesController) GetTags(c *gin.Context) {
+	claims, _ := services.NewClaimsFromContext(c)
+
+	tags, err := filesService.GetTags(claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+	// Response
+	response := make(map[string]interface{})
+	response["tags"] = tags
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    response,
+	})
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func (f *FilesController) UploadVersion(c *gin.Context) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Message: "Body must be a multipart/form-data",
+			Success: false,
+		})
+		return
+	}
+	idFile := c.Param("idFile")
+	limits := c.MustGet("file_limits").(*utils.FileLimits)
+	claims, _ := services.NewClaimsFromContext(c)
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			break
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		// Upload version
+		file, errRes := filesService.UploadVersion(
+			idFile,
+			claims,
+			part.FileName(),
+			part,
+			limits,
+		)
+		if errRes != nil {
+			c.AbortWithStatusJSON(errRes.StatusCode, &res.Response{
+				Success: false,
+				Message: errRes.Err.Error(),
+			})
+			return
+		}
+
+		c.JSON(201, &res.Response{
+			Success: true,
+			Data:    res.WrapFileRes(*file),
+		})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, res.Response{
+		Success: false,
+		Message: "Ha ocurrido un error tratando de leer el archivo",
+	})
+}
+
+func (f *FilesController) GetVersions(c *gin.Context) {
+	idFile := c.Param("idFile")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	versions, err := filesService.GetVersions(idFile, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    res.WrapFileVersionsRes(versions),
+	})
+}
+
+func (f *FilesController) GetVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: "Param version must be a number",
+		})
+		return
+	}
+	idFile := c.Param("idFile")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	token, errRes := filesService.GetVersion(idFile, claims.ID, version)
+	if errRes != nil {
+		c.AbortWithStatusJSON(errRes.StatusCode, &res.Response{
+			Success: false,
+			Message: errRes.Err.Error(),
+		})
+		return
+	}
+	// Response
+	response := make(map[string]interface{})
+	response["token"] = token
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    response,
+	})
+}
+
+func (f *FilesController) RestoreVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: "Param version must be a number",
+		})
+		return
+	}
+	idFile := c.Param("idFile")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	file, errRes := filesService.RestoreVersion(idFile, claims.ID, version)
+	if errRes != nil {
+		c.AbortWithStatusJSON(errRes.StatusCode, &res.Response{
+			Success: false,
+			Message: errRes.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    res.WrapFileRes(*file),
+	})
+}
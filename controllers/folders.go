@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Services
+var foldersService = services.NewFoldersService()
+
+type FoldersController struct{}
+
+func (f *FoldersController) CreateFolder(c *gin.Context) {
+	var folderData forms.FolderForm
+	if err := c.BindJSON(&folderData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	claims, _ := services.NewClaimsFromContext(c)
+
+	folder, err := foldersService.CreateFolder(folderData, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, &res.Response{
+		Success: true,
+		Data:    res.WrapFolderRes(*folder),
+	})
+}
+
+func (f *FoldersController) GetFolder(c *gin.Context) {
+	idFolder := c.Param("idFolder")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	content, err := foldersService.GetFolder(idFolder, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+	// Response
+	response := &res.FolderContentRes{
+		Breadcrumbs: res.WrapFoldersRes(content.Breadcrumbs),
+		Folders:     res.WrapFoldersRes(content.Folders),
+		Files:       res.WrapOwnFilesRes(content.Files),
+	}
+	if content.Folder != nil {
+		response.Folder = res.WrapFolderRes(*content.Folder)
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    response,
+	})
+}
+
+func (f *FoldersController) RenameFolder(c *gin.Context) {
+	var folderData forms.RenameFolderForm
+	if err := c.BindJSON(&folderData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	idFolder := c.Param("idFolder")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	err := foldersService.RenameFolder(idFolder, claims.ID, folderData.Name)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+	})
+}
+
+func (f *FoldersController) MoveFolder(c *gin.Context) {
+	var moveData forms.MoveForm
+	if err := c.BindJSON(&moveData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	idFolder := c.Param("idFolder")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	err := foldersService.MoveFolder(idFolder, claims.ID, moveData.Parent)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+	})
+}
+
+func (f *FoldersController) DeleteFolder(c *gin.Context) {
+	idFolder := c.Param("idFolder")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	err := foldersService.DeleteFolder(idFolder, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+	})
+}
+
+func (f *FoldersController) MoveFile(c *gin.Context) {
+	var moveData forms.MoveForm
+	if err := c.BindJSON(&moveData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	idFile := c.Param("idFile")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	err := foldersService.MoveFile(idFile, claims.ID, moveData.Parent)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+	})
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Services
+var linksService = services.NewLinksService()
+
+type LinksController struct{}
+
+func (l *LinksController) CreateLink(c *gin.Context) {
+	var linkData forms.LinkForm
+	if err := c.BindJSON(&linkData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	idFile := c.Param("idFile")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	link, err := linksService.CreateLink(idFile, claims.ID, linkData)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, &res.Response{
+		Success: true,
+		Data:    res.WrapLinkRes(*link),
+	})
+}
+
+func (l *LinksController) GetLinks(c *gin.Context) {
+	idFile := c.Param("idFile")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	links, err := linksService.GetLinks(idFile, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    res.WrapLinksRes(links),
+	})
+}
+
+func (l *LinksController) RevokeLink(c *gin.Context) {
+	idLink := c.Param("idLink")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	err := linksService.RevokeLink(idLink, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+	})
+}
+
+// Public, the password goes in the body so it is not logged
+func (l *LinksController) AccessLink(c *gin.Context) {
+	var accessData forms.LinkAccessForm
+	if c.Request.Method == http.MethodPost {
+		if err := c.BindJSON(&accessData); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	token := c.Param("token")
+
+	urlStr, file, err := linksService.AccessLink(token, accessData.Password)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+	// Response
+	response := make(map[string]interface{})
+	response["token"] = urlStr
+	response["filename"] = file.Filename
+	response["title"] = file.Title
+	response["type"] = file.Type
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    response,
+	})
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/gin-gonic/gin"
+)
+
+func (f *FilesController) GetPreview(c *gin.Context) {
+	page := 1
+	if c.Query("page") != "" {
+		pageQuery, err := strconv.Atoi(c.Query("page"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+				Success: false,
+				Message: "Query page must be a number",
+			})
+			return
+		}
+		page = pageQuery
+	}
+	idFile := c.Param("idFile")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	preview, errRes := filesService.GetPreview(idFile, page, claims)
+	if errRes != nil {
+		c.AbortWithStatusJSON(errRes.StatusCode, &res.Response{
+			Success: false,
+			Message: errRes.Err.Error(),
+		})
+		return
+	}
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data: res.WrapPreviewRes(
+			preview.Page,
+			preview.Pages,
+			preview.Type,
+			preview.Token,
+		),
+	})
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Services
+var quotasService = services.NewQuotasService()
+
+type QuotasController struct{}
+
+func (q *QuotasController) GetUsage(c *gin.Context) {
+	claims, _ := services.NewClaimsFromContext(c)
+
+	usage, err := quotasService.GetUsage(claims)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    res.WrapQuotaRes(usage.Used, usage.Quota, usage.Remaining),
+	})
+}
+
+func (q *QuotasController) SetUserQuota(c *gin.Context) {
+	var quotaData forms.QuotaForm
+	if err := c.BindJSON(&quotaData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	idUser := c.Param("idUser")
+
+	usage, err := quotasService.SetUserQuota(idUser, quotaData.Quota)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    res.WrapQuotaRes(usage.Used, usage.Quota, usage.Remaining),
+	})
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/gin-gonic/gin"
+)
+
+func (f *FilesController) SearchFiles(c *gin.Context) {
+	var query forms.SearchForm
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	claims, _ := services.NewClaimsFromContext(c)
+
+	results, err := filesService.SearchFiles(query, claims)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+	// Response
+	files := make([]*res.SearchResultRes, len(results))
+	for i, result := range results {
+		files[i] = res.WrapSearchResultRes(result.File, result.Snippet, result.Score)
+	}
+	response := make(map[string]interface{})
+	response["files"] = files
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    response,
+	})
+}
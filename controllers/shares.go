@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/gin-gonic/gin"
+)
+
+func (f *FilesController) ShareFile(c *gin.Context) {
+	var shareData forms.ShareForm
+	if err := c.BindJSON(&shareData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	idFile := c.Param("idFile")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	err := filesService.ShareFile(idFile, claims.ID, shareData)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+	})
+}
+
+func (f *FilesController) UnshareFile(c *gin.Context) {
+	var shareData forms.ShareForm
+	if err := c.ShouldBindQuery(&shareData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	idFile := c.Param("idFile")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	err := filesService.UnshareFile(idFile, claims.ID, shareData)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+	})
+}
+
+func (f *FilesController) GetSharedFiles(c *gin.Context) {
+	claims, _ := services.NewClaimsFromContext(c)
+
+	files, err := filesService.GetSharedFiles(claims)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    res.WrapFilesRes(files),
+	})
+}
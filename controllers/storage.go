@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Types shown by the browser without running scripts, the other
+// contents are always downloaded so an uploaded page can not run
+// on the origin of the API
+var inlineTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+	"video/mp4":       true,
+	"video/webm":      true,
+	"audio/mpeg":      true,
+	"audio/ogg":       true,
+}
+
+func isInlineType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && inlineTypes[mediaType]
+}
+
+// Services
+var storageService = services.NewStorageService()
+
+type StorageController struct{}
+
+func (s *StorageController) ServeFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	expires := c.Query("expires")
+	signature := c.Query("signature")
+
+	file, contentType, err := storageService.GetFile(key, expires, signature)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	disposition := "attachment"
+	if isInlineType(contentType) {
+		disposition = "inline"
+	}
+	c.DataFromReader(200, -1, contentType, file, map[string]string{
+		"Content-Disposition": mime.FormatMediaType(disposition, map[string]string{
+			"filename": path.Base(key),
+		}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func (s *StorageController) UploadFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	err := storageService.PutFile(
+		key,
+		c.Query("expires"),
+		c.Query("signature"),
+		c.Query("size"),
+		c.ContentType(),
+		c.Request.Body,
+	)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+	c.JSON(200, &res.Response{
+		Success: true,
+	})
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/services"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Services
+var uploadsService = services.NewUploadsService()
+
+type UploadsController struct{}
+
+func (u *UploadsController) InitUpload(c *gin.Context) {
+	var uploadData forms.UploadForm
+	if err := c.BindJSON(&uploadData); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	limits := c.MustGet("file_limits").(*utils.FileLimits)
+	claims, _ := services.NewClaimsFromContext(c)
+
+	upload, err := uploadsService.InitUpload(uploadData, limits, claims)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, &res.Response{
+		Success: true,
+		Data:    res.WrapUploadRes(*upload),
+	})
+}
+
+func (u *UploadsController) GetUpload(c *gin.Context) {
+	idUpload := c.Param("idUpload")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	upload, err := uploadsService.GetUpload(idUpload, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    res.WrapUploadRes(*upload),
+	})
+}
+
+func (u *UploadsController) UploadPart(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &res.Response{
+			Success: false,
+			Message: "Query offset must be a number",
+		})
+		return
+	}
+	idUpload := c.Param("idUpload")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	upload, errRes := uploadsService.UploadPart(idUpload, claims, offset, c.Request.Body)
+	if errRes != nil {
+		c.AbortWithStatusJSON(errRes.StatusCode, &res.Response{
+			Success: false,
+			Message: errRes.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+		Data:    res.WrapUploadRes(*upload),
+	})
+}
+
+func (u *UploadsController) CompleteUpload(c *gin.Context) {
+	idUpload := c.Param("idUpload")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	file, err := uploadsService.CompleteUpload(idUpload, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, &res.Response{
+		Success: true,
+		Data:    res.WrapFileRes(*file),
+	})
+}
+
+func (u *UploadsController) AbortUpload(c *gin.Context) {
+	idUpload := c.Param("idUpload")
+	claims, _ := services.NewClaimsFromContext(c)
+
+	err := uploadsService.AbortUpload(idUpload, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode, &res.Response{
+			Success: false,
+			Message: err.Err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, &res.Response{
+		Success: true,
+	})
+}
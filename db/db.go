@@ -43,6 +43,15 @@ func (mongo *MongoClient) CreateCollection(collectionName string, opts *options.
 	return db.CreateCollection(Ctx, collectionName, opts)
 }
 
+// Replace the validator of an existing collection
+func (mongo *MongoClient) UpdateValidator(collectionName string, validator bson.M) error {
+	db := mongo.client.Database(mongo.database)
+	return db.RunCommand(Ctx, bson.D{
+		{Key: "collMod", Value: collectionName},
+		{Key: "validator", Value: validator},
+	}).Err()
+}
+
 func NewMongoClient(host string) *mongo.Client {
 	uri := fmt.Sprintf(
 		"%s://%s:%s@%s",
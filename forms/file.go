@@ -1,9 +1,48 @@
 package forms
 
 type FileForm struct {
-	Title string `form:"title" binding:"required,min=3,max=100"`
+	Title  string `form:"title" binding:"required,min=3,max=100"`
+	Folder string `form:"folder"`
+	Rename bool   `form:"rename"`
+}
+
+type MoveForm struct {
+	Parent string `json:"parent"`
 }
 
 type PermissionsForm struct {
 	Permissions string `json:"permissions" binding:"required"`
 }
+
+type FilesQueryForm struct {
+	Permissions string   `form:"permissions"`
+	Page        int      `form:"page" binding:"omitempty,min=1"`
+	Limit       int      `form:"limit" binding:"omitempty,min=1,max=100"`
+	Sort        string   `form:"sort" binding:"omitempty,oneof=date title size type"`
+	Order       string   `form:"order" binding:"omitempty,oneof=asc desc"`
+	Search      string   `form:"search" binding:"max=100"`
+	Type        string   `form:"type" binding:"omitempty,oneof=image video audio text application"`
+	From        int64    `form:"from" binding:"omitempty,min=0"`
+	To          int64    `form:"to" binding:"omitempty,min=0"`
+	Tags        []string `form:"tags" binding:"max=10"`
+}
+
+// Only the fields sent are changed
+type UpdateFileForm struct {
+	Title       *string   `json:"title" binding:"omitempty,min=3,max=100"`
+	Description *string   `json:"description" binding:"omitempty,max=500"`
+	Tags        *[]string `json:"tags" binding:"omitempty,max=20,dive,min=1,max=30"`
+}
+
+// Files to download as zip, by id or by the filters
+type ZipForm struct {
+	Files       []string `json:"files" binding:"max=500"`
+	Folder      string   `json:"folder"`
+	Permissions string   `json:"permissions" binding:"omitempty,oneof=private public public_classroom"`
+}
+
+type SearchForm struct {
+	Search string `form:"q" binding:"required,min=2,max=100"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=50"`
+	Skip   int    `form:"skip" binding:"omitempty,min=0,max=1000"`
+}
@@ -0,0 +1,10 @@
+package forms
+
+type FolderForm struct {
+	Name   string `json:"name" binding:"required,min=1,max=100"`
+	Parent string `json:"parent"`
+}
+
+type RenameFolderForm struct {
+	Name string `json:"name" binding:"required,min=1,max=100"`
+}
@@ -0,0 +1,11 @@
+package forms
+
+type LinkForm struct {
+	ExpiresAt    int64  `json:"expires_at" binding:"omitempty,min=0"`
+	Password     string `json:"password" binding:"omitempty,min=4,max=72"`
+	MaxDownloads int    `json:"max_downloads" binding:"omitempty,min=0"`
+}
+
+type LinkAccessForm struct {
+	Password string `json:"password"`
+}
@@ -0,0 +1,6 @@
+package forms
+
+type QuotaForm struct {
+	// Bytes, null restores the quota of the user type
+	Quota *int64 `json:"quota" binding:"omitempty,min=0"`
+}
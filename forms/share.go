@@ -0,0 +1,7 @@
+package forms
+
+type ShareForm struct {
+	User     string `json:"user" form:"user"`
+	UserType string `json:"user_type" form:"user_type"`
+	Write    bool   `json:"write" form:"write"`
+}
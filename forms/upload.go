@@ -0,0 +1,17 @@
+package forms
+
+type UploadForm struct {
+	Title    string `json:"title" binding:"required,min=3,max=100"`
+	Filename string `json:"filename" binding:"required"`
+	Size     int64  `json:"size" binding:"required,min=1"`
+	Folder   string `json:"folder"`
+	Rename   bool   `json:"rename"`
+}
+
+type DirectUploadForm struct {
+	Title    string `json:"title" binding:"required,min=3,max=100"`
+	Filename string `json:"filename" binding:"required"`
+	Size     int64  `json:"size" binding:"required,min=1"`
+	Folder   string `json:"folder"`
+	Rename   bool   `json:"rename"`
+}
@@ -1,7 +1,39 @@
 package main
 
-import "github.com/CPU-commits/Intranet_BFiles/server"
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/CPU-commits/Intranet_BFiles/migrations"
+	"github.com/CPU-commits/Intranet_BFiles/server"
+)
+
+// migrate applies the pending migrations, migrate status lists them
+func migrate(args []string) {
+	if len(args) > 0 && args[0] == "status" {
+		status, err := migrations.GetStatus()
+		if err != nil {
+			log.Fatalf("Error reading migrations: %v", err)
+		}
+		for _, migration := range status {
+			applied := "pending"
+			if migration.Date != nil {
+				applied = migration.Date.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-28s %s\n", migration.Version, migration.Name, applied)
+		}
+		return
+	}
+	if err := migrations.Run(); err != nil {
+		log.Fatalf("Error applying migrations: %v", err)
+	}
+}
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
 	server.Init()
 }
@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Room for the multipart boundaries and the text fields
+const MULTIPART_OVERHEAD = 1 << 20
+
+// StreamMaxSizePerFile does not parse the body, it only caps it and
+// leaves the limits in the context so the handlers enforce them per
+// file while streaming
+func StreamMaxSizePerFile(maxSize float64, maxSizeStr string, maxFiles int) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request.Body = http.MaxBytesReader(
+			ctx.Writer,
+			ctx.Request.Body,
+			int64(maxSize)*int64(maxFiles)+MULTIPART_OVERHEAD,
+		)
+		ctx.Set("file_limits", &utils.FileLimits{
+			MaxSize:    int64(maxSize),
+			MaxSizeStr: maxSizeStr,
+			MaxFiles:   maxFiles,
+		})
+		ctx.Next()
+	}
+}
@@ -0,0 +1,197 @@
+package migrations
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var filesModel = new(models.FilesModel)
+var quotasModel = new(models.QuotasModel)
+
+// Files of the users, the ones of the classrooms have no owner
+var ownedFilesFilter = bson.M{
+	"status": true,
+	"user":   bson.M{"$gt": primitive.NilObjectID},
+}
+
+// The filename if no other file of the folder has it, else the
+// first free "name (n).ext"
+func freeFilename(file models.File) (string, error) {
+	ext := filepath.Ext(file.Filename)
+	base := strings.TrimSuffix(file.Filename, ext)
+	filename := file.Filename
+	for n := 2; ; n++ {
+		parent := bson.M{"$exists": false}
+		if !file.Parent.IsZero() {
+			parent = bson.M{"$eq": file.Parent}
+		}
+		count, err := filesModel.Use().CountDocuments(db.Ctx, bson.M{
+			"_id":      bson.M{"$ne": file.ID},
+			"user":     file.User,
+			"status":   true,
+			"parent":   parent,
+			"filename": filename,
+		})
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return filename, nil
+		}
+		filename = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+}
+
+// Filenames were unique across every user, but not enforced. The
+// repeated names of a folder are renamed before the unique index,
+// the oldest file keeps its name
+func uniqueFilenames() error {
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: ownedFilesFilter}},
+		bson.D{{Key: "$sort", Value: bson.M{"date": 1}}},
+		bson.D{{
+			Key: "$group",
+			Value: bson.M{
+				"_id": bson.M{
+					"user":     "$user",
+					"parent":   "$parent",
+					"filename": "$filename",
+				},
+				"files": bson.M{"$push": "$_id"},
+			},
+		}},
+		bson.D{{
+			Key: "$match",
+			Value: bson.M{
+				"files.1": bson.M{"$exists": true},
+			},
+		}},
+	}
+	var repeated []struct {
+		Files []primitive.ObjectID `bson:"files"`
+	}
+	cursor, err := filesModel.Use().Aggregate(db.Ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	if err := cursor.All(db.Ctx, &repeated); err != nil {
+		return err
+	}
+	for _, group := range repeated {
+		for _, idFile := range group.Files[1:] {
+			var file models.File
+			err := filesModel.Use().FindOne(db.Ctx, bson.M{"_id": idFile}).Decode(&file)
+			if err != nil {
+				return err
+			}
+			filename, err := freeFilename(file)
+			if err != nil {
+				return err
+			}
+			_, err = filesModel.Use().UpdateByID(db.Ctx, idFile, bson.M{
+				"$set": bson.M{"filename": filename},
+			})
+			if err != nil {
+				return err
+			}
+			log.Printf("File %s renamed to %s", idFile.Hex(), filename)
+		}
+	}
+	// Trashed files are left out, they are checked when restored
+	return createIndexes(models.FILES_COLLECTION, []mongo.IndexModel{{
+		Keys: bson.D{
+			{Key: "user", Value: 1},
+			{Key: "parent", Value: 1},
+			{Key: "filename", Value: 1},
+		},
+		Options: options.Index().
+			SetName("files_unique_filename").
+			SetUnique(true).
+			SetPartialFilterExpression(ownedFilesFilter),
+	}})
+}
+
+// Files uploaded before the size was recorded take it from the
+// storage, the missing objects are skipped
+func backfillFilesSize() error {
+	fileStorage := storage.NewStorage()
+	cursor, err := filesModel.Use().Find(db.Ctx, bson.M{
+		"size": bson.M{"$exists": false},
+		"key":  bson.M{"$ne": ""},
+	}, options.Find().SetProjection(bson.M{"key": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(db.Ctx)
+
+	for cursor.Next(db.Ctx) {
+		var file models.File
+		if err := cursor.Decode(&file); err != nil {
+			return err
+		}
+		size, _, err := fileStorage.HeadFile(file.Key)
+		if err != nil {
+			log.Printf("Size of %s not found: %v", file.Key, err)
+			continue
+		}
+		_, err = filesModel.Use().UpdateByID(db.Ctx, file.ID, bson.M{
+			"$set": bson.M{"size": size},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// The used space only counted the uploads made after the quotas,
+// it is computed again from the files of every user
+func backfillQuotasUsage() error {
+	cursor, err := filesModel.Use().Aggregate(db.Ctx, models.UsagePipeline(bson.M{
+		"user": bson.M{"$gt": primitive.NilObjectID},
+	}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(db.Ctx)
+
+	users := bson.A{}
+	for cursor.Next(db.Ctx) {
+		var usage struct {
+			User primitive.ObjectID `bson:"_id"`
+			Used int64              `bson:"used"`
+		}
+		if err := cursor.Decode(&usage); err != nil {
+			return err
+		}
+		_, err := quotasModel.Use().UpdateOne(db.Ctx, bson.M{
+			"user": usage.User,
+		}, bson.M{
+			"$set": bson.M{"used": usage.Used},
+		}, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+		users = append(users, usage.User)
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	// Users without files
+	_, err = quotasModel.Use().UpdateMany(db.Ctx, bson.M{
+		"user": bson.M{"$nin": users},
+	}, bson.M{
+		"$set": bson.M{"used": int64(0)},
+	})
+	return err
+}
@@ -0,0 +1,131 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Creating an index that already exists does nothing
+func createIndexes(collection string, indexes []mongo.IndexModel) error {
+	_, err := models.DbConnect.GetCollection(collection).Indexes().CreateMany(db.Ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("%s: %w", collection, err)
+	}
+	return nil
+}
+
+func createFilesIndexes() error {
+	return createIndexes(models.FILES_COLLECTION, []mongo.IndexModel{
+		// Files of an user, from the newest
+		{
+			Keys: bson.D{
+				{Key: "user", Value: 1},
+				{Key: "status", Value: 1},
+				{Key: "date", Value: -1},
+			},
+		},
+		// Every file sharing a content is updated by its key
+		{
+			Keys: bson.D{{Key: "key", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "parent", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "shares.user", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "shares.user_type", Value: 1}},
+		},
+		// Trash purge
+		{
+			Keys: bson.D{
+				{Key: "status", Value: 1},
+				{Key: "deleted_at", Value: 1},
+			},
+		},
+		// Full text search, the title weighs more than the content
+		{
+			Keys: bson.D{
+				{Key: "title", Value: "text"},
+				{Key: "description", Value: "text"},
+				{Key: "tags", Value: "text"},
+				{Key: "content", Value: "text"},
+			},
+			Options: options.Index().
+				SetName("files_text").
+				SetWeights(bson.M{
+					"title":       10,
+					"tags":        5,
+					"description": 3,
+					"content":     1,
+				}).
+				SetDefaultLanguage("spanish").
+				SetLanguageOverride("text_language"),
+		},
+	})
+}
+
+func createCollectionsIndexes() error {
+	indexes := map[string][]mongo.IndexModel{
+		// One blob per content
+		models.BLOBS_COLLECTION: {
+			{
+				Keys:    bson.D{{Key: "checksum", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{Key: "key", Value: 1}},
+			},
+		},
+		models.FOLDERS_COLLECTION: {
+			{
+				Keys: bson.D{
+					{Key: "user", Value: 1},
+					{Key: "parent", Value: 1},
+					{Key: "name", Value: 1},
+				},
+			},
+		},
+		models.LINKS_COLLECTION: {
+			{
+				Keys:    bson.D{{Key: "token", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{
+					{Key: "file", Value: 1},
+					{Key: "status", Value: 1},
+				},
+			},
+		},
+		// The quota is upserted, one per user
+		models.QUOTAS_COLLECTION: {
+			{
+				Keys:    bson.D{{Key: "user", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		models.UPLOADS_COLLECTION: {
+			{
+				Keys: bson.D{{Key: "expires_at", Value: 1}},
+			},
+		},
+		models.DIRECT_UPLOADS_COLLECTION: {
+			{
+				Keys: bson.D{{Key: "expires_at", Value: 1}},
+			},
+		},
+	}
+	for collection, collectionIndexes := range indexes {
+		if err := createIndexes(collection, collectionIndexes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
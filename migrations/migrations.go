@@ -0,0 +1,186 @@
+package migrations
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration changes the database from the previous version. Up must
+// be idempotent, it runs again if it fails before being recorded
+type Migration struct {
+	Version int
+	Name    string
+	Up      func() error
+}
+
+// Status of a migration, Date is nil while it is pending
+type Status struct {
+	Version int
+	Name    string
+	Date    *time.Time
+}
+
+// Version of the lock document, no migration uses it
+const LOCK_VERSION = 0
+
+// A lock older than this is left by an instance that died migrating
+const LOCK_TIMEOUT = 30 * time.Minute
+
+const LOCK_WAIT = 5 * time.Second
+
+var migrationsModel = new(models.MigrationsModel)
+
+// Applied in order. A version is never reused nor changed once
+// released, a schema change needs a new migration calling
+// applyValidators
+var migrations = []Migration{
+	{Version: 1, Name: "collections_validators", Up: applyValidators},
+	{Version: 2, Name: "files_indexes", Up: createFilesIndexes},
+	{Version: 3, Name: "collections_indexes", Up: createCollectionsIndexes},
+	{Version: 4, Name: "files_unique_filename", Up: uniqueFilenames},
+	{Version: 5, Name: "files_size", Up: backfillFilesSize},
+	{Version: 6, Name: "direct_uploads_confirmation", Up: applyValidators},
+	{Version: 7, Name: "quotas_usage", Up: backfillQuotasUsage},
+	{Version: 8, Name: "uploads_folder", Up: applyValidators},
+	{Version: 9, Name: "links_attempts", Up: applyValidators},
+}
+
+// Only one instance migrates, the others wait for it
+func lock() error {
+	for {
+		_, err := migrationsModel.Use().InsertOne(db.Ctx, migrationsModel.NewModel(LOCK_VERSION, "lock"))
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		_, err = migrationsModel.Use().DeleteOne(db.Ctx, bson.M{
+			"_id": LOCK_VERSION,
+			"date": bson.M{
+				"$lt": primitive.NewDateTimeFromTime(time.Now().Add(-LOCK_TIMEOUT)),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Waiting for the migrations of another instance")
+		time.Sleep(LOCK_WAIT)
+	}
+}
+
+func unlock() {
+	_, err := migrationsModel.Use().DeleteOne(db.Ctx, bson.M{
+		"_id": LOCK_VERSION,
+	})
+	if err != nil {
+		log.Printf("Error releasing the migrations lock: %v", err)
+	}
+}
+
+func getApplied() (map[int]models.Migration, error) {
+	var applied []models.Migration
+	cursor, err := migrationsModel.Use().Find(db.Ctx, bson.M{
+		"_id": bson.M{"$ne": LOCK_VERSION},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(db.Ctx, &applied); err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]models.Migration)
+	for _, migration := range applied {
+		byVersion[migration.Version] = migration
+	}
+	return byVersion, nil
+}
+
+// Run applies the pending migrations in order, it stops at the
+// first one failing
+func Run() error {
+	if err := lock(); err != nil {
+		return err
+	}
+	defer unlock()
+
+	applied, err := getApplied()
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		log.Printf("Applying migration %d %s", migration.Version, migration.Name)
+		if err := migration.Up(); err != nil {
+			return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		_, err := migrationsModel.Use().InsertOne(
+			db.Ctx,
+			migrationsModel.NewModel(migration.Version, migration.Name),
+		)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStatus lists every migration and when it was applied
+func GetStatus() ([]Status, error) {
+	applied, err := getApplied()
+	if err != nil {
+		return nil, err
+	}
+	var status []Status
+	for _, migration := range migrations {
+		migrationStatus := Status{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+		if appliedMigration, ok := applied[migration.Version]; ok {
+			date := appliedMigration.Date.Time()
+			migrationStatus.Date = &date
+		}
+		status = append(status, migrationStatus)
+	}
+	return status, nil
+}
+
+// Create the missing collections with their validator and update
+// the validator of the existing ones
+func applyValidators() error {
+	collections, err := models.DbConnect.GetCollections()
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool)
+	for _, collection := range collections {
+		exists[collection] = true
+	}
+	for collection, jsonSchema := range models.GetJSONSchemas() {
+		validator := bson.M{
+			"$jsonSchema": jsonSchema,
+		}
+		if exists[collection] {
+			err = models.DbConnect.UpdateValidator(collection, validator)
+		} else {
+			err = models.DbConnect.CreateCollection(collection, &options.CreateCollectionOptions{
+				Validator: validator,
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", collection, err)
+		}
+	}
+	return nil
+}
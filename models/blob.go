@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const BLOBS_COLLECTION = "blobs"
+
+// Blob is a stored content shared by every file with the same
+// checksum, it is deleted when Refs reaches zero
+type Blob struct {
+	ID       primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Checksum string             `json:"checksum" bson:"checksum"`
+	Key      string             `json:"key" bson:"key"`
+	URL      string             `json:"url" bson:"url"`
+	Size     int64              `json:"size" bson:"size"`
+	Refs     int                `json:"refs" bson:"refs"`
+	Date     primitive.DateTime `json:"date" bson:"date"`
+}
+
+type BlobsModel struct{}
+
+func (b *BlobsModel) Use() *mongo.Collection {
+	return DbConnect.GetCollection(BLOBS_COLLECTION)
+}
+
+func (b *BlobsModel) NewModel(checksum, key, url string, size int64) *Blob {
+	return &Blob{
+		Checksum: checksum,
+		Key:      key,
+		URL:      url,
+		Size:     size,
+		Refs:     1,
+		Date:     primitive.NewDateTimeFromTime(time.Now()),
+	}
+}
+
+func init() {
+	jsonSchemas[BLOBS_COLLECTION] = bson.M{
+		"bsonType": "object",
+		"required": []string{
+			"checksum",
+			"key",
+			"url",
+			"size",
+			"refs",
+			"date",
+		},
+		"properties": bson.M{
+			"checksum": bson.M{"bsonType": "string"},
+			"key":      bson.M{"bsonType": "string"},
+			"url":      bson.M{"bsonType": "string"},
+			"size":     bson.M{"bsonType": "long"},
+			"refs":     bson.M{"bsonType": "int"},
+			"date":     bson.M{"bsonType": "date"},
+		},
+	}
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"github.com/CPU-commits/Intranet_BFiles/db"
 	"github.com/CPU-commits/Intranet_BFiles/settings"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -17,3 +18,11 @@ type Models interface {
 	Use() *mongo.Collection
 	NewModel() interface{}
 }
+
+// Validators of the collections, the migrations create the
+// collections with them and update them
+var jsonSchemas = make(map[string]bson.M)
+
+func GetJSONSchemas() map[string]bson.M {
+	return jsonSchemas
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const DIRECT_UPLOADS_COLLECTION = "direct_uploads"
+
+// DirectUpload is a slot to upload a file straight to the storage,
+// the file is created when the upload is confirmed
+type DirectUpload struct {
+	ID               primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	User             primitive.ObjectID `json:"user" bson:"user"`
+	Title            string             `json:"title" bson:"title"`
+	Filename         string             `json:"filename" bson:"filename"`
+	OriginalFilename string             `json:"original_filename" bson:"original_filename"`
+	Key              string             `json:"key" bson:"key"`
+	URL              string             `json:"url" bson:"url"`
+	Type             string             `json:"type" bson:"type"`
+	Size             int64              `json:"size" bson:"size"`
+	Parent           primitive.ObjectID `json:"parent,omitempty" bson:"parent,omitempty"`
+	Date             primitive.DateTime `json:"date" bson:"date"`
+	ExpiresAt        primitive.DateTime `json:"expires_at" bson:"expires_at"`
+	Rename           bool               `json:"rename,omitempty" bson:"rename,omitempty"`
+	// Set while the upload is confirmed, a confirmed upload is kept
+	// until it expires so the upload URL can not be reused
+	ConfirmedAt primitive.DateTime `json:"confirmed_at,omitempty" bson:"confirmed_at,omitempty"`
+}
+
+type DirectUploadsModel struct{}
+
+func (d *DirectUploadsModel) Use() *mongo.Collection {
+	return DbConnect.GetCollection(DIRECT_UPLOADS_COLLECTION)
+}
+
+func (d *DirectUploadsModel) NewModel(
+	title,
+	filename,
+	originalFilename,
+	key,
+	url,
+	typeFile string,
+	idUser primitive.ObjectID,
+	size int64,
+	parent primitive.ObjectID,
+	expiresAt time.Time,
+) *DirectUpload {
+	return &DirectUpload{
+		User:             idUser,
+		Title:            title,
+		Filename:         filename,
+		OriginalFilename: originalFilename,
+		Key:              key,
+		URL:              url,
+		Type:             typeFile,
+		Size:             size,
+		Parent:           parent,
+		Date:             primitive.NewDateTimeFromTime(time.Now()),
+		ExpiresAt:        primitive.NewDateTimeFromTime(expiresAt),
+	}
+}
+
+func init() {
+	jsonSchemas[DIRECT_UPLOADS_COLLECTION] = bson.M{
+		"bsonType": "object",
+		"required": []string{
+			"user",
+			"title",
+			"filename",
+			"original_filename",
+			"key",
+			"url",
+			"type",
+			"size",
+			"date",
+			"expires_at",
+		},
+		"properties": bson.M{
+			"user": bson.M{"bsonType": "objectId"},
+			"title": bson.M{
+				"bsonType":  "string",
+				"maxLength": 100,
+			},
+			"filename":          bson.M{"bsonType": "string"},
+			"original_filename": bson.M{"bsonType": "string"},
+			"key":               bson.M{"bsonType": "string"},
+			"url":               bson.M{"bsonType": "string"},
+			"type":              bson.M{"bsonType": "string"},
+			"size":              bson.M{"bsonType": "long"},
+			"parent":            bson.M{"bsonType": "objectId"},
+			"date":              bson.M{"bsonType": "date"},
+			"expires_at":        bson.M{"bsonType": "date"},
+			"rename":            bson.M{"bsonType": "bool"},
+			"confirmed_at":      bson.M{"bsonType": "date"},
+		},
+	}
+}
@@ -14,17 +14,155 @@ import (
 
 const FILES_COLLECTION = "files"
 
+// Antivirus scan status. Files without status were uploaded while
+// scanning was disabled
+const (
+	SCAN_PENDING  = "pending"
+	SCAN_CLEAN    = "clean"
+	SCAN_INFECTED = "infected"
+	SCAN_ERROR    = "error"
+)
+
+// FileVersion is a previous content of a file, kept after
+// uploading a new version
+type FileVersion struct {
+	Version    int                `json:"version" bson:"version"`
+	Filename   string             `json:"filename" bson:"filename"`
+	Key        string             `json:"key" bson:"key"`
+	URL        string             `json:"url" bson:"url"`
+	Type       string             `json:"type" bson:"type"`
+	Size       int64              `json:"size" bson:"size"`
+	Checksum   string             `json:"checksum,omitempty" bson:"checksum,omitempty"`
+	ScanStatus string             `json:"scan_status,omitempty" bson:"scan_status,omitempty"`
+	User       primitive.ObjectID `json:"user" bson:"user"`
+	Date       primitive.DateTime `json:"date" bson:"date"`
+}
+
+// Status of the preview of documents and text files
+const (
+	PREVIEW_PENDING = "pending"
+	PREVIEW_READY   = "ready"
+	PREVIEW_ERROR   = "error"
+)
+
+// FilePreview is a page of a document rendered as image, or the
+// beginning of a text file
+type FilePreview struct {
+	Page int    `json:"page" bson:"page"`
+	Key  string `json:"key" bson:"key"`
+	URL  string `json:"url" bson:"url"`
+	Type string `json:"type" bson:"type"`
+	Size int64  `json:"size" bson:"size"`
+}
+
+// FileVariant is a resized copy of an image, stored next to it
+type FileVariant struct {
+	Name   string `json:"name" bson:"name"`
+	Key    string `json:"key" bson:"key"`
+	URL    string `json:"url" bson:"url"`
+	Type   string `json:"type" bson:"type"`
+	Width  int    `json:"width" bson:"width"`
+	Height int    `json:"height" bson:"height"`
+	Size   int64  `json:"size" bson:"size"`
+}
+
+// FileShare grants access to an user or to every user of a type
+type FileShare struct {
+	User     primitive.ObjectID `json:"user,omitempty" bson:"user,omitempty"`
+	UserType string             `json:"user_type,omitempty" bson:"user_type,omitempty"`
+	Write    bool               `json:"write" bson:"write"`
+	Date     primitive.DateTime `json:"date" bson:"date"`
+}
+
 type File struct {
-	ID          primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
-	Filename    string             `json:"filename" bson:"filename"`
-	Key         string             `json:"key" bson:"key"`
-	URL         string             `json:"url" bson:"url"`
-	Title       string             `json:"title" bson:"title"`
-	Type        string             `json:"type" bson:"type"`
-	User        primitive.ObjectID `json:"user" bson:"user"`
-	Status      bool               `json:"status" bson:"status"`
-	Permissions string             `json:"permissions" bson:"permissions"`
-	Date        primitive.DateTime `json:"date" bson:"date"`
+	ID            primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Filename      string             `json:"filename" bson:"filename"`
+	Key           string             `json:"key" bson:"key"`
+	URL           string             `json:"url" bson:"url"`
+	Title         string             `json:"title" bson:"title"`
+	Description   string             `json:"description,omitempty" bson:"description,omitempty"`
+	Tags          []string           `json:"tags,omitempty" bson:"tags,omitempty"`
+	Type          string             `json:"type" bson:"type"`
+	User          primitive.ObjectID `json:"user" bson:"user"`
+	Status        bool               `json:"status" bson:"status"`
+	Permissions   string             `json:"permissions" bson:"permissions"`
+	Date          primitive.DateTime `json:"date" bson:"date"`
+	Size          int64              `json:"size,omitempty" bson:"size,omitempty"`
+	Checksum      string             `json:"checksum,omitempty" bson:"checksum,omitempty"`
+	Parent        primitive.ObjectID `json:"parent,omitempty" bson:"parent,omitempty"`
+	DeletedAt     primitive.DateTime `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	Shares        []FileShare        `json:"shares,omitempty" bson:"shares,omitempty"`
+	Classroom     primitive.ObjectID `json:"classroom,omitempty" bson:"classroom,omitempty"`
+	Version       int                `json:"version,omitempty" bson:"version,omitempty"`
+	UploadedBy    primitive.ObjectID `json:"uploaded_by,omitempty" bson:"uploaded_by,omitempty"`
+	Versions      []FileVersion      `json:"versions,omitempty" bson:"versions,omitempty"`
+	ScanStatus    string             `json:"scan_status,omitempty" bson:"scan_status,omitempty"`
+	Variants      []FileVariant      `json:"variants,omitempty" bson:"variants,omitempty"`
+	PreviewStatus string             `json:"preview_status,omitempty" bson:"preview_status,omitempty"`
+	Previews      []FilePreview      `json:"previews,omitempty" bson:"previews,omitempty"`
+	Content       string             `json:"-" bson:"content,omitempty"`
+}
+
+// Files without version are the first one
+func (f *File) CurrentVersion() int {
+	if f.Version == 0 {
+		return 1
+	}
+	return f.Version
+}
+
+// Read access is granted by any share, write access only by
+// the shares with write
+func (f *File) IsSharedWith(idUser, userType string, write bool) bool {
+	for _, share := range f.Shares {
+		if write && !share.Write {
+			continue
+		}
+		if !share.User.IsZero() && share.User.Hex() == idUser {
+			return true
+		}
+		if share.UserType != "" && share.UserType == userType {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *File) GetVersion(version int) *FileVersion {
+	for i := range f.Versions {
+		if f.Versions[i].Version == version {
+			return &f.Versions[i]
+		}
+	}
+	return nil
+}
+
+func (f *File) GetVariant(name string) *FileVariant {
+	for i := range f.Variants {
+		if f.Variants[i].Name == name {
+			return &f.Variants[i]
+		}
+	}
+	return nil
+}
+
+func (f *File) GetPreview(page int) *FilePreview {
+	for i := range f.Previews {
+		if f.Previews[i].Page == page {
+			return &f.Previews[i]
+		}
+	}
+	return nil
+}
+
+func (f *File) LastVersion() int {
+	last := f.CurrentVersion()
+	for _, version := range f.Versions {
+		if version.Version > last {
+			last = version.Version
+		}
+	}
+	return last
 }
 
 type FilesModel struct{}
@@ -85,16 +223,7 @@ func (f *FilesModel) NewModel(filename, key, url, title, typeFile, idUser, permi
 }
 
 func init() {
-	collections, errC := DbConnect.GetCollections()
-	if errC != nil {
-		panic(errC)
-	}
-	for _, collection := range collections {
-		if collection == FILES_COLLECTION {
-			return
-		}
-	}
-	var jsonSchema = bson.M{
+	jsonSchemas[FILES_COLLECTION] = bson.M{
 		"bsonType": "object",
 		"required": []string{
 			"filename",
@@ -112,6 +241,17 @@ func init() {
 				"bsonType":  "string",
 				"maxLength": 100,
 			},
+			"description": bson.M{
+				"bsonType":  "string",
+				"maxLength": 500,
+			},
+			"tags": bson.M{
+				"bsonType": bson.A{"array"},
+				"items": bson.M{
+					"bsonType":  "string",
+					"maxLength": 30,
+				},
+			},
 			"key":         bson.M{"bsonType": "string"},
 			"img":         bson.M{"bsonType": "objectId"},
 			"url":         bson.M{"bsonType": "string"},
@@ -119,16 +259,78 @@ func init() {
 			"status":      bson.M{"bsonType": "bool"},
 			"date":        bson.M{"bsonType": "date"},
 			"type":        bson.M{"bsonType": "string"},
+			"size":        bson.M{"bsonType": "long"},
+			"checksum":    bson.M{"bsonType": "string"},
+			"parent":      bson.M{"bsonType": "objectId"},
+			"deleted_at":  bson.M{"bsonType": "date"},
+			"classroom":   bson.M{"bsonType": "objectId"},
+			"shares": bson.M{
+				"bsonType": bson.A{"array"},
+				"items": bson.M{
+					"bsonType": "object",
+					"required": []string{"write", "date"},
+					"properties": bson.M{
+						"user":      bson.M{"bsonType": "objectId"},
+						"user_type": bson.M{"enum": bson.A{DIRECTOR, DIRECTIVE, TEACHER, ATTORNEY, STUDENT_DIRECTIVE, STUDENT}},
+						"write":     bson.M{"bsonType": "bool"},
+						"date":      bson.M{"bsonType": "date"},
+					},
+				},
+			},
+			"version":     bson.M{"bsonType": "int"},
+			"uploaded_by": bson.M{"bsonType": "objectId"},
+			"scan_status": bson.M{"enum": bson.A{SCAN_PENDING, SCAN_CLEAN, SCAN_INFECTED, SCAN_ERROR}},
+			"variants": bson.M{
+				"bsonType": bson.A{"array"},
+				"items": bson.M{
+					"bsonType": "object",
+					"required": []string{"name", "key", "url", "type"},
+					"properties": bson.M{
+						"name":   bson.M{"bsonType": "string"},
+						"key":    bson.M{"bsonType": "string"},
+						"url":    bson.M{"bsonType": "string"},
+						"type":   bson.M{"bsonType": "string"},
+						"width":  bson.M{"bsonType": "int"},
+						"height": bson.M{"bsonType": "int"},
+						"size":   bson.M{"bsonType": "long"},
+					},
+				},
+			},
+			"content":        bson.M{"bsonType": "string"},
+			"preview_status": bson.M{"enum": bson.A{PREVIEW_PENDING, PREVIEW_READY, PREVIEW_ERROR}},
+			"previews": bson.M{
+				"bsonType": bson.A{"array"},
+				"items": bson.M{
+					"bsonType": "object",
+					"required": []string{"page", "key", "url", "type"},
+					"properties": bson.M{
+						"page": bson.M{"bsonType": "int"},
+						"key":  bson.M{"bsonType": "string"},
+						"url":  bson.M{"bsonType": "string"},
+						"type": bson.M{"bsonType": "string"},
+						"size": bson.M{"bsonType": "long"},
+					},
+				},
+			},
+			"versions": bson.M{
+				"bsonType": bson.A{"array"},
+				"items": bson.M{
+					"bsonType": "object",
+					"required": []string{"version", "key", "url", "user", "date"},
+					"properties": bson.M{
+						"version":     bson.M{"bsonType": "int"},
+						"filename":    bson.M{"bsonType": "string"},
+						"key":         bson.M{"bsonType": "string"},
+						"url":         bson.M{"bsonType": "string"},
+						"type":        bson.M{"bsonType": "string"},
+						"size":        bson.M{"bsonType": "long"},
+						"checksum":    bson.M{"bsonType": "string"},
+						"scan_status": bson.M{"enum": bson.A{SCAN_PENDING, SCAN_CLEAN, SCAN_INFECTED, SCAN_ERROR}},
+						"user":        bson.M{"bsonType": "objectId"},
+						"date":        bson.M{"bsonType": "date"},
+					},
+				},
+			},
 		},
 	}
-	var validators = bson.M{
-		"$jsonSchema": jsonSchema,
-	}
-	opts := &options.CreateCollectionOptions{
-		Validator: validators,
-	}
-	err := DbConnect.CreateCollection(FILES_COLLECTION, opts)
-	if err != nil {
-		panic(err)
-	}
 }
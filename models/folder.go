@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const FOLDERS_COLLECTION = "folders"
+
+// Folder groups the files of an user, the folders without
+// parent are on the root
+type Folder struct {
+	ID     primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Name   string             `json:"name" bson:"name"`
+	User   primitive.ObjectID `json:"user" bson:"user"`
+	Parent primitive.ObjectID `json:"parent,omitempty" bson:"parent,omitempty"`
+	Date   primitive.DateTime `json:"date" bson:"date"`
+}
+
+type FoldersModel struct{}
+
+func (f *FoldersModel) Use() *mongo.Collection {
+	return DbConnect.GetCollection(FOLDERS_COLLECTION)
+}
+
+func (f *FoldersModel) NewModel(name, idUser string, parent primitive.ObjectID) (*Folder, error) {
+	idObjUser, err := primitive.ObjectIDFromHex(idUser)
+	if err != nil {
+		return nil, err
+	}
+	return &Folder{
+		Name:   name,
+		User:   idObjUser,
+		Parent: parent,
+		Date:   primitive.NewDateTimeFromTime(time.Now()),
+	}, nil
+}
+
+func init() {
+	jsonSchemas[FOLDERS_COLLECTION] = bson.M{
+		"bsonType": "object",
+		"required": []string{
+			"name",
+			"user",
+			"date",
+		},
+		"properties": bson.M{
+			"name": bson.M{
+				"bsonType":  "string",
+				"maxLength": 100,
+			},
+			"user":   bson.M{"bsonType": "objectId"},
+			"parent": bson.M{"bsonType": "objectId"},
+			"date":   bson.M{"bsonType": "date"},
+		},
+	}
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const LINKS_COLLECTION = "links"
+
+// Link gives access to a file without an account through an
+// opaque token
+type Link struct {
+	ID           primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	File         primitive.ObjectID `json:"file" bson:"file"`
+	User         primitive.ObjectID `json:"user" bson:"user"`
+	Token        string             `json:"token" bson:"token"`
+	Password     string             `json:"-" bson:"password,omitempty"`
+	ExpiresAt    primitive.DateTime `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	MaxDownloads int                `json:"max_downloads" bson:"max_downloads"`
+	Downloads    int                `json:"downloads" bson:"downloads"`
+	Status       bool               `json:"status" bson:"status"`
+	Date         primitive.DateTime `json:"date" bson:"date"`
+	// Wrong passwords since the last access, reaching the limit
+	// locks the link until LockedUntil
+	FailedAttempts int                `json:"-" bson:"failed_attempts,omitempty"`
+	LockedUntil    primitive.DateTime `json:"-" bson:"locked_until,omitempty"`
+}
+
+func (l *Link) IsExpired() bool {
+	return l.ExpiresAt != 0 && l.ExpiresAt.Time().Before(time.Now())
+}
+
+type LinksModel struct{}
+
+func (l *LinksModel) Use() *mongo.Collection {
+	return DbConnect.GetCollection(LINKS_COLLECTION)
+}
+
+func (l *LinksModel) NewModel(
+	idFile,
+	idUser primitive.ObjectID,
+	token,
+	password string,
+	expiresAt time.Time,
+	maxDownloads int,
+) *Link {
+	link := &Link{
+		File:         idFile,
+		User:         idUser,
+		Token:        token,
+		Password:     password,
+		MaxDownloads: maxDownloads,
+		Downloads:    0,
+		Status:       true,
+		Date:         primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if !expiresAt.IsZero() {
+		link.ExpiresAt = primitive.NewDateTimeFromTime(expiresAt)
+	}
+	return link
+}
+
+func init() {
+	jsonSchemas[LINKS_COLLECTION] = bson.M{
+		"bsonType": "object",
+		"required": []string{
+			"file",
+			"user",
+			"token",
+			"max_downloads",
+			"downloads",
+			"status",
+			"date",
+		},
+		"properties": bson.M{
+			"file":            bson.M{"bsonType": "objectId"},
+			"user":            bson.M{"bsonType": "objectId"},
+			"token":           bson.M{"bsonType": "string"},
+			"password":        bson.M{"bsonType": "string"},
+			"expires_at":      bson.M{"bsonType": "date"},
+			"max_downloads":   bson.M{"bsonType": "int", "minimum": 0},
+			"downloads":       bson.M{"bsonType": "int", "minimum": 0},
+			"status":          bson.M{"bsonType": "bool"},
+			"date":            bson.M{"bsonType": "date"},
+			"failed_attempts": bson.M{"bsonType": "int", "minimum": 0},
+			"locked_until":    bson.M{"bsonType": "date"},
+		},
+	}
+}
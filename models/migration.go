@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const MIGRATIONS_COLLECTION = "migrations"
+
+// Migration records a migration applied to the database
+type Migration struct {
+	Version int                `json:"version" bson:"_id"`
+	Name    string             `json:"name" bson:"name"`
+	Date    primitive.DateTime `json:"date" bson:"date"`
+}
+
+type MigrationsModel struct{}
+
+func (m *MigrationsModel) Use() *mongo.Collection {
+	return DbConnect.GetCollection(MIGRATIONS_COLLECTION)
+}
+
+func (m *MigrationsModel) NewModel(version int, name string) *Migration {
+	return &Migration{
+		Version: version,
+		Name:    name,
+		Date:    primitive.NewDateTimeFromTime(time.Now()),
+	}
+}
+
+func init() {
+	jsonSchemas[MIGRATIONS_COLLECTION] = bson.M{
+		"bsonType": "object",
+		"required": []string{
+			"name",
+			"date",
+		},
+		"properties": bson.M{
+			"_id":  bson.M{"bsonType": "int"},
+			"name": bson.M{"bsonType": "string"},
+			"date": bson.M{"bsonType": "date"},
+		},
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const QUOTAS_COLLECTION = "quotas"
+
+// Quota tracks the bytes stored by an user, Quota overrides
+// the quota of its user type when set
+type Quota struct {
+	ID       primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	User     primitive.ObjectID `json:"user" bson:"user"`
+	UserType string             `json:"user_type,omitempty" bson:"user_type,omitempty"`
+	Used     int64              `json:"used" bson:"used"`
+	Quota    *int64             `json:"quota,omitempty" bson:"quota,omitempty"`
+}
+
+type QuotasModel struct{}
+
+func (q *QuotasModel) Use() *mongo.Collection {
+	return DbConnect.GetCollection(QUOTAS_COLLECTION)
+}
+
+// Bytes stored by the owners of the matched files, a file takes
+// space with its versions until it is purged from the trash
+func UsagePipeline(match bson.M) mongo.Pipeline {
+	return mongo.Pipeline{
+		bson.D{{Key: "$match", Value: match}},
+		bson.D{{
+			Key: "$group",
+			Value: bson.M{
+				"_id": "$user",
+				"used": bson.M{
+					"$sum": bson.M{
+						"$add": bson.A{
+							bson.M{"$ifNull": bson.A{"$size", 0}},
+							bson.M{"$sum": "$versions.size"},
+						},
+					},
+				},
+			},
+		}},
+	}
+}
+
+func init() {
+	jsonSchemas[QUOTAS_COLLECTION] = bson.M{
+		"bsonType": "object",
+		"required": []string{
+			"user",
+			"used",
+		},
+		"properties": bson.M{
+			"user":      bson.M{"bsonType": "objectId"},
+			"user_type": bson.M{"bsonType": "string"},
+			"used":      bson.M{"bsonType": "long"},
+			"quota":     bson.M{"bsonType": "long"},
+		},
+	}
+}
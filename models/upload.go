@@ -0,0 +1,134 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const UPLOADS_COLLECTION = "uploads"
+
+type UploadPart struct {
+	Number int    `json:"number" bson:"number"`
+	ETag   string `json:"etag" bson:"etag"`
+	Size   int64  `json:"size" bson:"size"`
+}
+
+// Upload is a resumable upload session, mapped to a multipart
+// upload of the storage
+type Upload struct {
+	ID               primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	User             primitive.ObjectID `json:"user" bson:"user"`
+	Title            string             `json:"title" bson:"title"`
+	Filename         string             `json:"filename" bson:"filename"`
+	OriginalFilename string             `json:"original_filename" bson:"original_filename"`
+	Key              string             `json:"key" bson:"key"`
+	UploadID         string             `json:"upload_id" bson:"upload_id"`
+	Size             int64              `json:"size" bson:"size"`
+	ChunkSize        int64              `json:"chunk_size" bson:"chunk_size"`
+	Offset           int64              `json:"offset" bson:"offset"`
+	Parts            []UploadPart       `json:"parts" bson:"parts"`
+	Type             string             `json:"type,omitempty" bson:"type,omitempty"`
+	HashState        []byte             `json:"-" bson:"hash_state,omitempty"`
+	Date             primitive.DateTime `json:"date" bson:"date"`
+	ExpiresAt        primitive.DateTime `json:"expires_at" bson:"expires_at"`
+	Rename           bool               `json:"rename,omitempty" bson:"rename,omitempty"`
+	UserType         string             `json:"user_type,omitempty" bson:"user_type,omitempty"`
+	Parent           primitive.ObjectID `json:"parent,omitempty" bson:"parent,omitempty"`
+	// Set when the parts were joined and the content stored, the
+	// file is still to be created
+	Location string `json:"-" bson:"location,omitempty"`
+}
+
+type UploadsModel struct{}
+
+func (u *UploadsModel) Use() *mongo.Collection {
+	return DbConnect.GetCollection(UPLOADS_COLLECTION)
+}
+
+func (u *UploadsModel) NewModel(
+	title,
+	filename,
+	originalFilename,
+	key,
+	uploadID,
+	idUser string,
+	size,
+	chunkSize int64,
+	expiresAt time.Time,
+) (*Upload, error) {
+	idObjUser, err := primitive.ObjectIDFromHex(idUser)
+	if err != nil {
+		return nil, err
+	}
+	return &Upload{
+		User:             idObjUser,
+		Title:            title,
+		Filename:         filename,
+		OriginalFilename: originalFilename,
+		Key:              key,
+		UploadID:         uploadID,
+		Size:             size,
+		ChunkSize:        chunkSize,
+		Offset:           0,
+		Parts:            []UploadPart{},
+		Date:             primitive.NewDateTimeFromTime(time.Now()),
+		ExpiresAt:        primitive.NewDateTimeFromTime(expiresAt),
+	}, nil
+}
+
+func init() {
+	jsonSchemas[UPLOADS_COLLECTION] = bson.M{
+		"bsonType": "object",
+		"required": []string{
+			"user",
+			"title",
+			"filename",
+			"original_filename",
+			"key",
+			"upload_id",
+			"size",
+			"chunk_size",
+			"offset",
+			"parts",
+			"date",
+			"expires_at",
+		},
+		"properties": bson.M{
+			"user": bson.M{"bsonType": "objectId"},
+			"title": bson.M{
+				"bsonType":  "string",
+				"maxLength": 100,
+			},
+			"filename":          bson.M{"bsonType": "string"},
+			"original_filename": bson.M{"bsonType": "string"},
+			"key":               bson.M{"bsonType": "string"},
+			"upload_id":         bson.M{"bsonType": "string"},
+			"size":              bson.M{"bsonType": "long"},
+			"chunk_size":        bson.M{"bsonType": "long"},
+			"offset":            bson.M{"bsonType": "long"},
+			"parts": bson.M{
+				"bsonType": bson.A{"array"},
+				"items": bson.M{
+					"bsonType": "object",
+					"required": []string{"number", "etag", "size"},
+					"properties": bson.M{
+						"number": bson.M{"bsonType": "int"},
+						"etag":   bson.M{"bsonType": "string"},
+						"size":   bson.M{"bsonType": "long"},
+					},
+				},
+			},
+			"type":       bson.M{"bsonType": "string"},
+			"hash_state": bson.M{"bsonType": "binData"},
+			"date":       bson.M{"bsonType": "date"},
+			"expires_at": bson.M{"bsonType": "date"},
+			"rename":     bson.M{"bsonType": "bool"},
+			"user_type":  bson.M{"bsonType": "string"},
+			"parent":     bson.M{"bsonType": "objectId"},
+			"location":   bson.M{"bsonType": "string"},
+		},
+	}
+}
@@ -0,0 +1,160 @@
+package previews
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/settings"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+)
+
+// Pages rendered of every document
+const PREVIEW_PAGES = 3
+
+// Width in pixels of the rendered pages
+const PREVIEW_WIDTH = 800
+
+// Bytes kept of the text previews
+const TEXT_PREVIEW_SIZE = 4096
+
+const RENDER_TIMEOUT = 2 * time.Minute
+
+var ErrNoPreview = errors.New("el archivo no tiene vista previa")
+
+var settingsData = settings.GetSettings()
+
+var officeExtensions = map[string]bool{
+	".doc":  true,
+	".docx": true,
+	".xls":  true,
+	".xlsx": true,
+	".ppt":  true,
+	".pptx": true,
+	".odt":  true,
+	".ods":  true,
+	".odp":  true,
+}
+
+func IsOfficeFile(ext string) bool {
+	return officeExtensions[strings.ToLower(ext)]
+}
+
+func IsDocument(ext string) bool {
+	return strings.ToLower(ext) == ".pdf" || IsOfficeFile(ext)
+}
+
+func IsText(ext string) bool {
+	return utils.IsCodeFile(strings.ToLower(ext)) || strings.ToLower(ext) == ".txt"
+}
+
+// First bytes of the file, cut at the last complete line
+func RenderText(file io.Reader) ([]byte, error) {
+	text, err := io.ReadAll(io.LimitReader(file, TEXT_PREVIEW_SIZE+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(text) > TEXT_PREVIEW_SIZE {
+		text = text[:TEXT_PREVIEW_SIZE]
+		if i := strings.LastIndexByte(string(text), '\n'); i > 0 {
+			text = text[:i+1]
+		}
+	}
+	return text, nil
+}
+
+func command(name, defaultName string) string {
+	if name == "" {
+		return defaultName
+	}
+	return name
+}
+
+func run(cmd *exec.Cmd) error {
+	output, err := cmd.CombinedOutput()
+	if err != nil && len(output) > 0 {
+		return fmt.Errorf("%s: %s", filepath.Base(cmd.Path), strings.TrimSpace(string(output)))
+	}
+	return err
+}
+
+// Render the first pages of a PDF or office document as PNG images,
+// office documents are converted to PDF first
+func RenderDocument(file io.Reader, ext string) ([][]byte, error) {
+	if !IsDocument(ext) {
+		return nil, ErrNoPreview
+	}
+	dir, err := os.MkdirTemp("", "preview")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), RENDER_TIMEOUT)
+	defer cancel()
+
+	input := filepath.Join(dir, "document"+strings.ToLower(ext))
+	dst, err := os.Create(input)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(dst, file)
+	dst.Close()
+	if err != nil {
+		return nil, err
+	}
+	if IsOfficeFile(ext) {
+		convert := exec.CommandContext(
+			ctx,
+			command(settingsData.OFFICE_CONVERTER, "soffice"),
+			"--headless",
+			"--convert-to",
+			"pdf",
+			"--outdir",
+			dir,
+			input,
+		)
+		if err := run(convert); err != nil {
+			return nil, err
+		}
+		input = filepath.Join(dir, "document.pdf")
+	}
+	render := exec.CommandContext(
+		ctx,
+		command(settingsData.PDF_RENDERER, "pdftoppm"),
+		"-png",
+		"-f", "1",
+		"-l", strconv.Itoa(PREVIEW_PAGES),
+		"-scale-to-x", strconv.Itoa(PREVIEW_WIDTH),
+		"-scale-to-y", "-1",
+		input,
+		filepath.Join(dir, "page"),
+	)
+	if err := run(render); err != nil {
+		return nil, err
+	}
+	// Pages are named page-1.png or page-01.png, depending on the
+	// number of pages of the document
+	paths, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var pages [][]byte
+	for _, path := range paths {
+		page, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
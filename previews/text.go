@@ -0,0 +1,86 @@
+package previews
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Bytes kept of the text of every file for the search
+const SEARCH_TEXT_SIZE = 100000
+
+// Pages of the PDFs read for the search
+const SEARCH_PDF_PAGES = 50
+
+func IsSearchable(ext string) bool {
+	return strings.ToLower(ext) == ".pdf" || IsText(ext)
+}
+
+// Spaces are collapsed and the text is cut to SEARCH_TEXT_SIZE
+// without splitting characters
+func cleanText(text string) string {
+	text = strings.Join(strings.Fields(strings.ToValidUTF8(text, " ")), " ")
+	if len(text) <= SEARCH_TEXT_SIZE {
+		return text
+	}
+	cut := SEARCH_TEXT_SIZE
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
+
+// ExtractText reads the text of PDFs, plain text and code files
+func ExtractText(file io.Reader, ext string) (string, error) {
+	if IsText(ext) {
+		text, err := io.ReadAll(io.LimitReader(file, SEARCH_TEXT_SIZE))
+		if err != nil {
+			return "", err
+		}
+		return cleanText(string(text)), nil
+	}
+	if strings.ToLower(ext) != ".pdf" {
+		return "", ErrNoPreview
+	}
+	dir, err := os.MkdirTemp("", "text")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), RENDER_TIMEOUT)
+	defer cancel()
+
+	input := filepath.Join(dir, "document.pdf")
+	dst, err := os.Create(input)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(dst, file)
+	dst.Close()
+	if err != nil {
+		return "", err
+	}
+	output := filepath.Join(dir, "document.txt")
+	extract := exec.CommandContext(
+		ctx,
+		command(settingsData.PDF_TEXT_EXTRACTOR, "pdftotext"),
+		"-enc", "UTF-8",
+		"-l", strconv.Itoa(SEARCH_PDF_PAGES),
+		input,
+		output,
+	)
+	if err := run(extract); err != nil {
+		return "", err
+	}
+	text, err := os.ReadFile(output)
+	if err != nil {
+		return "", err
+	}
+	return cleanText(string(text)), nil
+}
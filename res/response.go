@@ -2,6 +2,7 @@ package res
 
 import (
 	"github.com/CPU-commits/Intranet_BFiles/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type OID struct {
@@ -12,21 +13,73 @@ type Date struct {
 	Date int `json:"$date"`
 }
 
+func wrapOptionalOID(id primitive.ObjectID) *OID {
+	if id.IsZero() {
+		return nil
+	}
+	return &OID{
+		ID: id.Hex(),
+	}
+}
+
 type FileRes struct {
-	ID          OID    `json:"_id"`
-	Filename    string `json:"filename"`
-	Key         string `json:"key"`
-	URL         string `json:"url"`
-	User        OID    `json:"user"`
-	Title       string `json:"title"`
-	Type        string `json:"type"`
-	Status      bool   `json:"status"`
-	Permissions string `json:"permissions"`
-	Date        Date   `json:"date"`
+	ID            OID               `json:"_id"`
+	Filename      string            `json:"filename"`
+	Key           string            `json:"key"`
+	URL           string            `json:"url"`
+	User          OID               `json:"user"`
+	Title         string            `json:"title"`
+	Description   string            `json:"description,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+	Type          string            `json:"type"`
+	Status        bool              `json:"status"`
+	Permissions   string            `json:"permissions"`
+	Date          Date              `json:"date"`
+	Size          int64             `json:"size"`
+	Checksum      string            `json:"checksum,omitempty"`
+	Version       int               `json:"version"`
+	Parent        *OID              `json:"parent,omitempty"`
+	DeletedAt     *Date             `json:"deleted_at,omitempty"`
+	Shares        []*FileShareRes   `json:"shares,omitempty"`
+	Classroom     *OID              `json:"classroom,omitempty"`
+	ScanStatus    string            `json:"scan_status,omitempty"`
+	Variants      []*FileVariantRes `json:"variants,omitempty"`
+	PreviewStatus string            `json:"preview_status,omitempty"`
+	Pages         int               `json:"pages,omitempty"`
 }
 
+type FileVariantRes struct {
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Type   string `json:"type"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Size   int64  `json:"size"`
+}
+
+type FileShareRes struct {
+	User     *OID   `json:"user,omitempty"`
+	UserType string `json:"user_type,omitempty"`
+	Write    bool   `json:"write"`
+	Date     Date   `json:"date"`
+}
+
+// The file without its shares, who else can read a file is only
+// shown to its owner
 func WrapFileRes(file models.File) *FileRes {
-	return &FileRes{
+	fileRes := wrapFile(file)
+	fileRes.Shares = nil
+	return fileRes
+}
+
+// The file with its shares, only for the owner
+func WrapOwnFileRes(file models.File) *FileRes {
+	return wrapFile(file)
+}
+
+func wrapFile(file models.File) *FileRes {
+	fileRes := &FileRes{
 		ID: OID{
 			ID: file.ID.Hex(),
 		},
@@ -37,13 +90,50 @@ func WrapFileRes(file models.File) *FileRes {
 			ID: file.User.Hex(),
 		},
 		Title:       file.Title,
+		Description: file.Description,
+		Tags:        file.Tags,
 		Type:        file.Type,
 		Status:      file.Status,
 		Permissions: file.Permissions,
 		Date: Date{
 			Date: int(file.Date.Time().Unix()),
 		},
+		Size:          file.Size,
+		Checksum:      file.Checksum,
+		Version:       file.CurrentVersion(),
+		Parent:        wrapOptionalOID(file.Parent),
+		Classroom:     wrapOptionalOID(file.Classroom),
+		ScanStatus:    file.ScanStatus,
+		PreviewStatus: file.PreviewStatus,
+		Pages:         len(file.Previews),
+	}
+	for _, variant := range file.Variants {
+		fileRes.Variants = append(fileRes.Variants, &FileVariantRes{
+			Name:   variant.Name,
+			Key:    variant.Key,
+			URL:    variant.URL,
+			Type:   variant.Type,
+			Width:  variant.Width,
+			Height: variant.Height,
+			Size:   variant.Size,
+		})
 	}
+	for _, share := range file.Shares {
+		fileRes.Shares = append(fileRes.Shares, &FileShareRes{
+			User:     wrapOptionalOID(share.User),
+			UserType: share.UserType,
+			Write:    share.Write,
+			Date: Date{
+				Date: int(share.Date.Time().Unix()),
+			},
+		})
+	}
+	if file.DeletedAt != 0 {
+		fileRes.DeletedAt = &Date{
+			Date: int(file.DeletedAt.Time().Unix()),
+		}
+	}
+	return fileRes
 }
 
 func WrapFilesRes(files []models.File) []*FileRes {
@@ -54,8 +144,267 @@ func WrapFilesRes(files []models.File) []*FileRes {
 	return filesRes
 }
 
+func WrapOwnFilesRes(files []models.File) []*FileRes {
+	var filesRes []*FileRes
+	for _, file := range files {
+		filesRes = append(filesRes, WrapOwnFileRes(file))
+	}
+	return filesRes
+}
+
 type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"body"`
 }
+
+type FileVersionRes struct {
+	Version    int    `json:"version"`
+	Filename   string `json:"filename"`
+	Type       string `json:"type"`
+	Size       int64  `json:"size"`
+	Checksum   string `json:"checksum,omitempty"`
+	ScanStatus string `json:"scan_status,omitempty"`
+	User       OID    `json:"user"`
+	Date       Date   `json:"date"`
+}
+
+func WrapFileVersionsRes(versions []models.FileVersion) []*FileVersionRes {
+	var versionsRes []*FileVersionRes
+	for _, version := range versions {
+		versionsRes = append(versionsRes, &FileVersionRes{
+			Version:    version.Version,
+			Filename:   version.Filename,
+			Type:       version.Type,
+			Size:       version.Size,
+			Checksum:   version.Checksum,
+			ScanStatus: version.ScanStatus,
+			User: OID{
+				ID: version.User.Hex(),
+			},
+			Date: Date{
+				Date: int(version.Date.Time().Unix()),
+			},
+		})
+	}
+	return versionsRes
+}
+
+type FolderRes struct {
+	ID     OID    `json:"_id"`
+	Name   string `json:"name"`
+	User   OID    `json:"user"`
+	Parent *OID   `json:"parent,omitempty"`
+	Date   Date   `json:"date"`
+}
+
+func WrapFolderRes(folder models.Folder) *FolderRes {
+	return &FolderRes{
+		ID: OID{
+			ID: folder.ID.Hex(),
+		},
+		Name: folder.Name,
+		User: OID{
+			ID: folder.User.Hex(),
+		},
+		Parent: wrapOptionalOID(folder.Parent),
+		Date: Date{
+			Date: int(folder.Date.Time().Unix()),
+		},
+	}
+}
+
+func WrapFoldersRes(folders []models.Folder) []*FolderRes {
+	var foldersRes []*FolderRes
+	for _, folder := range folders {
+		foldersRes = append(foldersRes, WrapFolderRes(folder))
+	}
+	return foldersRes
+}
+
+type FolderContentRes struct {
+	Folder      *FolderRes   `json:"folder"`
+	Breadcrumbs []*FolderRes `json:"breadcrumbs"`
+	Folders     []*FolderRes `json:"folders"`
+	Files       []*FileRes   `json:"files"`
+}
+
+type UploadRes struct {
+	ID        OID    `json:"_id"`
+	Title     string `json:"title"`
+	Filename  string `json:"filename"`
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunk_size"`
+	Offset    int64  `json:"offset"`
+	Date      Date   `json:"date"`
+	ExpiresAt Date   `json:"expires_at"`
+}
+
+func WrapUploadRes(upload models.Upload) *UploadRes {
+	return &UploadRes{
+		ID: OID{
+			ID: upload.ID.Hex(),
+		},
+		Title:     upload.Title,
+		Filename:  upload.Filename,
+		Size:      upload.Size,
+		ChunkSize: upload.ChunkSize,
+		Offset:    upload.Offset,
+		Date: Date{
+			Date: int(upload.Date.Time().Unix()),
+		},
+		ExpiresAt: Date{
+			Date: int(upload.ExpiresAt.Time().Unix()),
+		},
+	}
+}
+
+type DirectUploadRes struct {
+	ID        OID               `json:"_id"`
+	Title     string            `json:"title"`
+	Filename  string            `json:"filename"`
+	Size      int64             `json:"size"`
+	Type      string            `json:"type"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	Date      Date              `json:"date"`
+	ExpiresAt Date              `json:"expires_at"`
+}
+
+func WrapDirectUploadRes(
+	upload models.DirectUpload,
+	urlStr,
+	method string,
+	headers map[string]string,
+) *DirectUploadRes {
+	return &DirectUploadRes{
+		ID: OID{
+			ID: upload.ID.Hex(),
+		},
+		Title:    upload.Title,
+		Filename: upload.Filename,
+		Size:     upload.Size,
+		Type:     upload.Type,
+		URL:      urlStr,
+		Method:   method,
+		Headers:  headers,
+		Date: Date{
+			Date: int(upload.Date.Time().Unix()),
+		},
+		ExpiresAt: Date{
+			Date: int(upload.ExpiresAt.Time().Unix()),
+		},
+	}
+}
+
+type LinkRes struct {
+	ID           OID    `json:"_id"`
+	File         OID    `json:"file"`
+	Token        string `json:"token"`
+	HasPassword  bool   `json:"has_password"`
+	ExpiresAt    *Date  `json:"expires_at,omitempty"`
+	MaxDownloads int    `json:"max_downloads"`
+	Downloads    int    `json:"downloads"`
+	Status       bool   `json:"status"`
+	Date         Date   `json:"date"`
+}
+
+func WrapLinkRes(link models.Link) *LinkRes {
+	linkRes := &LinkRes{
+		ID: OID{
+			ID: link.ID.Hex(),
+		},
+		File: OID{
+			ID: link.File.Hex(),
+		},
+		Token:        link.Token,
+		HasPassword:  link.Password != "",
+		MaxDownloads: link.MaxDownloads,
+		Downloads:    link.Downloads,
+		Status:       link.Status,
+		Date: Date{
+			Date: int(link.Date.Time().Unix()),
+		},
+	}
+	if link.ExpiresAt != 0 {
+		linkRes.ExpiresAt = &Date{
+			Date: int(link.ExpiresAt.Time().Unix()),
+		}
+	}
+	return linkRes
+}
+
+func WrapLinksRes(links []models.Link) []*LinkRes {
+	var linksRes []*LinkRes
+	for _, link := range links {
+		linksRes = append(linksRes, WrapLinkRes(link))
+	}
+	return linksRes
+}
+
+// Quota and remaining are -1 when unlimited
+type QuotaRes struct {
+	Used      int64 `json:"used"`
+	Quota     int64 `json:"quota"`
+	Remaining int64 `json:"remaining"`
+}
+
+func WrapQuotaRes(used, quota, remaining int64) *QuotaRes {
+	return &QuotaRes{
+		Used:      used,
+		Quota:     quota,
+		Remaining: remaining,
+	}
+}
+
+type BatchFileRes struct {
+	Filename   string   `json:"filename"`
+	Success    bool     `json:"success"`
+	StatusCode int      `json:"status_code"`
+	Message    string   `json:"message,omitempty"`
+	File       *FileRes `json:"file,omitempty"`
+}
+
+func WrapBatchFileRes(filename string, file *models.File, statusCode int, message string) *BatchFileRes {
+	batchFile := &BatchFileRes{
+		Filename:   filename,
+		Success:    file != nil,
+		StatusCode: statusCode,
+		Message:    message,
+	}
+	if file != nil {
+		batchFile.File = WrapFileRes(*file)
+	}
+	return batchFile
+}
+
+type SearchResultRes struct {
+	File    *FileRes `json:"file"`
+	Snippet string   `json:"snippet,omitempty"`
+	Score   float64  `json:"score"`
+}
+
+func WrapSearchResultRes(file models.File, snippet string, score float64) *SearchResultRes {
+	return &SearchResultRes{
+		File:    WrapFileRes(file),
+		Snippet: snippet,
+		Score:   score,
+	}
+}
+
+type PreviewRes struct {
+	Page  int    `json:"page"`
+	Pages int    `json:"pages"`
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
+
+func WrapPreviewRes(page, pages int, typePreview, token string) *PreviewRes {
+	return &PreviewRes{
+		Page:  page,
+		Pages: pages,
+		Type:  typePreview,
+		Token: token,
+	}
+}
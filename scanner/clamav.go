@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const CLAMAV_CHUNK_SIZE = 64 * 1024
+
+const CLAMAV_TIMEOUT = 5 * time.Minute
+
+// ClamAV talks with clamd using the INSTREAM command. The address
+// is tcp://host:port or unix:///path/to/clamd.sock
+type ClamAV struct {
+	network string
+	address string
+}
+
+func (c *ClamAV) Scan(file io.Reader) (*Result, error) {
+	conn, err := net.DialTimeout(c.network, c.address, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(CLAMAV_TIMEOUT))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+	// Chunks are prefixed by their length, a zero length ends the stream
+	chunk := make([]byte, CLAMAV_CHUNK_SIZE)
+	size := make([]byte, 4)
+	for {
+		n, err := file.Read(chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return nil, err
+			}
+			if _, err := conn.Write(chunk[:n]); err != nil {
+				return nil, err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return parseReply(string(bytes.TrimRight(reply, "\x00")))
+}
+
+// Replies are "stream: OK", "stream: <signature> FOUND" or
+// "<message> ERROR"
+func parseReply(reply string) (*Result, error) {
+	reply = strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case reply == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(reply, "FOUND"):
+		return &Result{
+			Infected:  true,
+			Signature: strings.TrimSpace(strings.TrimSuffix(reply, "FOUND")),
+		}, nil
+	default:
+		return nil, fmt.Errorf("clamav: %s", reply)
+	}
+}
+
+func NewClamAV(address string) *ClamAV {
+	network := "tcp"
+	if strings.HasPrefix(address, "unix://") {
+		network = "unix"
+		address = strings.TrimPrefix(address, "unix://")
+	} else {
+		address = strings.TrimPrefix(address, "tcp://")
+	}
+	return &ClamAV{
+		network: network,
+		address: address,
+	}
+}
@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// Local clamd answering every INSTREAM with reply, the received
+// content is sent to the channel
+func stubClamd(t *testing.T, network, address, reply string) (net.Listener, <-chan []byte) {
+	t.Helper()
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		command := make([]byte, len("zINSTREAM\x00"))
+		if _, err := io.ReadFull(conn, command); err != nil || string(command) != "zINSTREAM\x00" {
+			conn.Write([]byte("UNKNOWN COMMAND\x00"))
+			return
+		}
+		var content bytes.Buffer
+		size := make([]byte, 4)
+		for {
+			if _, err := io.ReadFull(conn, size); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size)
+			if n == 0 {
+				break
+			}
+			if _, err := io.CopyN(&content, conn, int64(n)); err != nil {
+				return
+			}
+		}
+		received <- content.Bytes()
+		conn.Write([]byte(reply + "\x00"))
+	}()
+	return listener, received
+}
+
+func TestClamAVScan(t *testing.T) {
+	// Bigger than a chunk, so it is sent in several
+	content := bytes.Repeat([]byte("intranet"), CLAMAV_CHUNK_SIZE/4)
+
+	tests := []struct {
+		name      string
+		reply     string
+		infected  bool
+		signature string
+		err       bool
+	}{
+		{name: "clean", reply: "stream: OK"},
+		{
+			name:      "infected",
+			reply:     "stream: Eicar-Test-Signature FOUND",
+			infected:  true,
+			signature: "Eicar-Test-Signature",
+		},
+		{name: "error", reply: "INSTREAM size limit exceeded. ERROR", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			listener, received := stubClamd(t, "tcp", "127.0.0.1:0", test.reply)
+			clamav := NewClamAV("tcp://" + listener.Addr().String())
+
+			result, err := clamav.Scan(bytes.NewReader(content))
+			if !bytes.Equal(<-received, content) {
+				t.Fatal("clamd did not receive the content")
+			}
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Infected != test.infected || result.Signature != test.signature {
+				t.Fatalf("unexpected result %+v", result)
+			}
+		})
+	}
+}
+
+func TestClamAVScanUnix(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	_, received := stubClamd(t, "unix", socket, "stream: OK")
+	clamav := NewClamAV("unix://" + socket)
+
+	result, err := clamav.Scan(bytes.NewReader([]byte("file")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(<-received) != "file" || result.Infected {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,35 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	NONE_DRIVER   = "none"
+	CLAMAV_DRIVER = "clamav"
+)
+
+// Result of a scan, Signature names the threat found
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner analyzes the content of a file looking for malware
+type Scanner interface {
+	Scan(file io.Reader) (*Result, error)
+}
+
+// Without driver no scanner is returned, so uploads are not scanned.
+// The address is the one of the driver
+func NewScanner(driver, address string) Scanner {
+	switch driver {
+	case CLAMAV_DRIVER:
+		return NewClamAV(address)
+	case NONE_DRIVER, "":
+		return nil
+	default:
+		panic(fmt.Sprintf("unknown scanner driver %s", driver))
+	}
+}
@@ -9,10 +9,12 @@ import (
 
 	"github.com/CPU-commits/Intranet_BFiles/controllers"
 	"github.com/CPU-commits/Intranet_BFiles/middlewares"
+	"github.com/CPU-commits/Intranet_BFiles/migrations"
 	"github.com/CPU-commits/Intranet_BFiles/models"
 	"github.com/CPU-commits/Intranet_BFiles/res"
 	"github.com/CPU-commits/Intranet_BFiles/services"
 	"github.com/CPU-commits/Intranet_BFiles/settings"
+	"github.com/CPU-commits/Intranet_BFiles/storage"
 	ratelimit "github.com/JGLTechnologies/gin-rate-limit"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/secure"
@@ -110,7 +112,7 @@ func Init() {
 
 		router.Use(cors.New(cors.Config{
 			AllowOrigins:     []string{httpOrigin, httpsOrigin},
-			AllowMethods:     []string{"GET", "OPTIONS", "PUT", "DELETE", "POST"},
+			AllowMethods:     []string{"GET", "OPTIONS", "PUT", "PATCH", "DELETE", "POST"},
 			AllowCredentials: true,
 			AllowWebSockets:  false,
 			AllowHeaders:     []string{"*"},
@@ -143,8 +145,18 @@ func Init() {
 		}
 	}*/
 	router.Use(secure.New(secureConfig))
+	// Migrations, before anything uses the database
+	if settingsData.MIGRATE_ON_START {
+		if err := migrations.Run(); err != nil {
+			log.Fatalf("Error applying migrations: %v", err)
+		}
+	}
 	// Init nats subscribers
 	services.InitFilesNats()
+	// Init background jobs
+	services.InitUploadsCleaner()
+	services.InitTrashPurger()
+	services.InitScanWorker()
 	// Rate limit
 	store := ratelimit.InMemoryStore(&ratelimit.InMemoryOptions{
 		Rate:  time.Second,
@@ -159,12 +171,6 @@ func Init() {
 	files := router.Group(
 		"/api/files",
 		middlewares.JWTMiddleware(),
-		middlewares.MaxSizePerFile(
-			MAX_FILE_SIZE,
-			MAX_FILE_SIZE_STR,
-			MAX_FILES,
-			"file",
-		),
 	)
 	{
 		// Init controllers
@@ -179,10 +185,26 @@ func Init() {
 			}),
 			filesController.GetFiles,
 		)
+		files.GET(
+			"/search",
+			filesController.SearchFiles,
+		)
 		files.GET(
 			"/get_file/:idFile",
 			filesController.GetFile,
 		)
+		files.GET(
+			"/preview/:idFile",
+			filesController.GetPreview,
+		)
+		files.GET(
+			"/download/:idFile",
+			filesController.DownloadFile,
+		)
+		files.POST(
+			"/download_zip",
+			filesController.DownloadZip,
+		)
 		files.POST(
 			"/upload_file",
 			middlewares.RolesMiddleware([]string{
@@ -190,8 +212,68 @@ func Init() {
 				models.DIRECTIVE,
 				models.TEACHER,
 			}),
+			middlewares.StreamMaxSizePerFile(
+				MAX_FILE_SIZE,
+				MAX_FILE_SIZE_STR,
+				1,
+			),
 			filesController.UploadFile,
 		)
+		files.POST(
+			"/upload_files",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			middlewares.StreamMaxSizePerFile(
+				MAX_FILE_SIZE,
+				MAX_FILE_SIZE_STR,
+				MAX_FILES,
+			),
+			filesController.UploadFiles,
+		)
+		files.POST(
+			"/upload_version/:idFile",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			middlewares.StreamMaxSizePerFile(
+				MAX_FILE_SIZE,
+				MAX_FILE_SIZE_STR,
+				1,
+			),
+			filesController.UploadVersion,
+		)
+		files.GET(
+			"/get_versions/:idFile",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			filesController.GetVersions,
+		)
+		files.GET(
+			"/get_version/:idFile/:version",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			filesController.GetVersion,
+		)
+		files.PUT(
+			"/restore_version/:idFile/:version",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			filesController.RestoreVersion,
+		)
 		files.PUT(
 			"/change_permissions/:idFile",
 			middlewares.RolesMiddleware([]string{
@@ -201,6 +283,24 @@ func Init() {
 			}),
 			filesController.ChangePermissions,
 		)
+		files.PATCH(
+			"/update_file/:idFile",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			filesController.UpdateFile,
+		)
+		files.GET(
+			"/get_tags",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			filesController.GetTags,
+		)
 		files.DELETE(
 			"/delete_file/:idFile",
 			middlewares.RolesMiddleware([]string{
@@ -210,7 +310,160 @@ func Init() {
 			}),
 			filesController.DeleteFile,
 		)
+		files.POST(
+			"/share_file/:idFile",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			filesController.ShareFile,
+		)
+		files.DELETE(
+			"/unshare_file/:idFile",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			filesController.UnshareFile,
+		)
+		files.GET(
+			"/get_shared_files",
+			filesController.GetSharedFiles,
+		)
+		files.GET(
+			"/get_trash",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			filesController.GetTrash,
+		)
+		files.PUT(
+			"/restore_file/:idFile",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			filesController.RestoreFile,
+		)
+		files.DELETE(
+			"/purge_file/:idFile",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			filesController.PurgeFile,
+		)
+	}
+	folders := router.Group(
+		"/api/files/folders",
+		middlewares.JWTMiddleware(),
+		middlewares.RolesMiddleware([]string{
+			models.DIRECTOR,
+			models.DIRECTIVE,
+			models.TEACHER,
+		}),
+	)
+	{
+		// Init controllers
+		foldersController := new(controllers.FoldersController)
+		// Define routes
+		folders.GET("", foldersController.GetFolder)
+		folders.GET("/:idFolder", foldersController.GetFolder)
+		folders.POST("", foldersController.CreateFolder)
+		folders.PUT("/:idFolder/rename", foldersController.RenameFolder)
+		folders.PUT("/:idFolder/move", foldersController.MoveFolder)
+		folders.DELETE("/:idFolder", foldersController.DeleteFolder)
+		folders.PUT("/move_file/:idFile", foldersController.MoveFile)
+	}
+	quotas := router.Group(
+		"/api/files/quota",
+		middlewares.JWTMiddleware(),
+	)
+	{
+		// Init controllers
+		quotasController := new(controllers.QuotasController)
+		// Define routes
+		quotas.GET(
+			"",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+				models.DIRECTIVE,
+				models.TEACHER,
+			}),
+			quotasController.GetUsage,
+		)
+		quotas.PUT(
+			"/:idUser",
+			middlewares.RolesMiddleware([]string{
+				models.DIRECTOR,
+			}),
+			quotasController.SetUserQuota,
+		)
 	}
+	links := router.Group(
+		"/api/files/links",
+		middlewares.JWTMiddleware(),
+		middlewares.RolesMiddleware([]string{
+			models.DIRECTOR,
+			models.DIRECTIVE,
+			models.TEACHER,
+		}),
+	)
+	// Init controllers
+	linksController := new(controllers.LinksController)
+	{
+		// Define routes
+		links.POST("/:idFile", linksController.CreateLink)
+		links.GET("/:idFile", linksController.GetLinks)
+		links.DELETE("/revoke/:idLink", linksController.RevokeLink)
+	}
+	// Route public links
+	router.GET("/api/files/shared/:token", linksController.AccessLink)
+	router.POST("/api/files/shared/:token", linksController.AccessLink)
+	uploads := router.Group(
+		"/api/files/uploads",
+		middlewares.JWTMiddleware(),
+		middlewares.RolesMiddleware([]string{
+			models.DIRECTOR,
+			models.DIRECTIVE,
+			models.TEACHER,
+		}),
+		middlewares.StreamMaxSizePerFile(
+			MAX_FILE_SIZE,
+			MAX_FILE_SIZE_STR,
+			1,
+		),
+	)
+	{
+		// Init controllers
+		uploadsController := new(controllers.UploadsController)
+		// Define routes
+		uploads.POST("", uploadsController.InitUpload)
+		uploads.GET("/:idUpload", uploadsController.GetUpload)
+		uploads.PATCH("/:idUpload", uploadsController.UploadPart)
+		uploads.POST("/:idUpload/complete", uploadsController.CompleteUpload)
+		uploads.DELETE("/:idUpload", uploadsController.AbortUpload)
+		// Direct uploads to the storage
+		uploads.POST("/direct", uploadsController.InitDirectUpload)
+		uploads.POST("/direct/:idUpload/confirm", uploadsController.ConfirmDirectUpload)
+		uploads.DELETE("/direct/:idUpload", uploadsController.AbortDirectUpload)
+	}
+	// Route signed storage (local and memory drivers)
+	storageController := new(controllers.StorageController)
+	router.GET(
+		storage.SERVE_PATH+"*key",
+		storageController.ServeFile,
+	)
+	router.PUT(
+		storage.SERVE_PATH+"*key",
+		storageController.UploadFile,
+	)
 	// Route docs
 	// router.GET("/api/news/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	// Route healthz
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var blobsModel = new(models.BlobsModel)
+
+var blobsService *BlobsService
+
+type BlobsService struct{}
+
+// Register the uploaded content. If the same content was already
+// stored the new object is deleted and stored points to the
+// existing one
+func (b *BlobsService) store(stored *storedFile) error {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var blob *models.Blob
+	err := blobsModel.Use().FindOneAndUpdate(db.Ctx, bson.M{
+		"checksum": stored.Checksum,
+	}, bson.D{{
+		Key: "$inc",
+		Value: bson.M{
+			"refs": 1,
+		},
+	}}, opts).Decode(&blob)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if blob == nil {
+		_, err := blobsModel.Use().InsertOne(db.Ctx, blobsModel.NewModel(
+			stored.Checksum,
+			stored.Key,
+			stored.Location,
+			stored.Size,
+		))
+		// Another upload of the same content won the insert
+		if mongo.IsDuplicateKeyError(err) {
+			return b.store(stored)
+		}
+		return err
+	}
+	if blob.Key != stored.Key {
+		if err := fileStorage.DeleteFile(stored.Key); err != nil {
+			return err
+		}
+		stored.Key = blob.Key
+		stored.Location = blob.URL
+	}
+	return nil
+}
+
+// Drop a reference to the content of the key, deleting it from
+// the storage if it was the last one. Contents stored before the
+// blobs are deleted directly
+func (b *BlobsService) release(key string) error {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var blob *models.Blob
+	err := blobsModel.Use().FindOneAndUpdate(db.Ctx, bson.M{
+		"key": key,
+	}, bson.D{{
+		Key: "$inc",
+		Value: bson.M{
+			"refs": -1,
+		},
+	}}, opts).Decode(&blob)
+	if err == mongo.ErrNoDocuments {
+		return b.deleteContent(key)
+	}
+	if err != nil {
+		return err
+	}
+	if blob.Refs > 0 {
+		return nil
+	}
+	// Another upload of the same content may take the blob again
+	// meanwhile, then the content stays
+	result, err := blobsModel.Use().DeleteOne(db.Ctx, bson.M{
+		"_id":  blob.ID,
+		"refs": bson.M{"$lte": 0},
+	})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount != 1 {
+		return nil
+	}
+	return b.deleteContent(key)
+}
+
+func (b *BlobsService) deleteContent(key string) error {
+	if err := fileStorage.DeleteFile(key); err != nil {
+		return err
+	}
+	filesService.deleteDerived(key)
+	return nil
+}
+
+func NewBlobsService() *BlobsService {
+	if blobsService == nil {
+		blobsService = &BlobsService{}
+	}
+	return blobsService
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/stack"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	CLASSROOM_MEMBER_SUBJECT = "is_user_in_classroom"
+	CLASSROOM_CACHE_DURATION = time.Minute
+)
+
+type classroomMember struct {
+	isMember  bool
+	expiresAt time.Time
+}
+
+// Short lived cache of the answers of the classroom service
+var classroomCache = struct {
+	lock    sync.Mutex
+	members map[string]classroomMember
+}{
+	members: make(map[string]classroomMember),
+}
+
+func getCachedMember(key string) (bool, bool) {
+	classroomCache.lock.Lock()
+	defer classroomCache.lock.Unlock()
+
+	member, ok := classroomCache.members[key]
+	if !ok {
+		return false, false
+	}
+	if time.Now().After(member.expiresAt) {
+		delete(classroomCache.members, key)
+		return false, false
+	}
+	return member.isMember, true
+}
+
+func setCachedMember(key string, isMember bool) {
+	classroomCache.lock.Lock()
+	defer classroomCache.lock.Unlock()
+
+	now := time.Now()
+	// Drop the expired answers so the cache does not grow forever
+	for cacheKey, member := range classroomCache.members {
+		if now.After(member.expiresAt) {
+			delete(classroomCache.members, cacheKey)
+		}
+	}
+	classroomCache.members[key] = classroomMember{
+		isMember:  isMember,
+		expiresAt: now.Add(CLASSROOM_CACHE_DURATION),
+	}
+}
+
+// Ask the classroom service if the user belongs to the classroom
+func isUserInClassroom(idClassroom primitive.ObjectID, claims *Claims) (bool, error) {
+	key := idClassroom.Hex() + ":" + claims.ID
+	if isMember, ok := getCachedMember(key); ok {
+		return isMember, nil
+	}
+	data, err := json.Marshal(&stack.NatsGolangReq{
+		Pattern: CLASSROOM_MEMBER_SUBJECT,
+		Data: map[string]string{
+			"id_user":      claims.ID,
+			"user_type":    claims.UserType,
+			"id_classroom": idClassroom.Hex(),
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	msg, err := nats_service.Request(CLASSROOM_MEMBER_SUBJECT, data)
+	if err != nil {
+		return false, err
+	}
+	var response stack.NatsNestJSRes
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		return false, err
+	}
+	isMember, ok := response.Response.(bool)
+	if !ok {
+		return false, errors.New("respuesta inválida del servicio de aulas")
+	}
+	setCachedMember(key, isMember)
+	return isMember, nil
+}
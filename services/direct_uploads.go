@@ -0,0 +1,424 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/storage"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Time to upload the file and confirm it, the upload URL itself
+// expires after storage.TOKEN_DURATION
+const DIRECT_UPLOAD_EXPIRATION = time.Hour
+
+var directUploadsModel = new(models.DirectUploadsModel)
+
+// DirectUploadSlot tells the client how to send the file
+type DirectUploadSlot struct {
+	Upload  *models.DirectUpload
+	URL     string
+	Method  string
+	Headers map[string]string
+}
+
+func (u *UploadsService) getDirectUpload(idUpload, idUser string) (*models.DirectUpload, *ErrorRes) {
+	idObjUpload, err := primitive.ObjectIDFromHex(idUpload)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	var upload *models.DirectUpload
+	cursor := directUploadsModel.Use().FindOne(db.Ctx, bson.D{{
+		Key:   "_id",
+		Value: idObjUpload,
+	}})
+	if err := cursor.Decode(&upload); err != nil {
+		if err.Error() == db.NO_SINGLE_DOCUMENT {
+			return nil, &ErrorRes{
+				Err:        errors.New("la subida no existe o ha expirado"),
+				StatusCode: http.StatusNotFound,
+			}
+		}
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if upload.User.Hex() != idUser {
+		return nil, &ErrorRes{
+			Err:        errors.New("la subida le pertenece a otro usuario"),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	return upload, nil
+}
+
+func (u *UploadsService) InitDirectUpload(
+	uploadData forms.DirectUploadForm,
+	limits *utils.FileLimits,
+	claims *Claims,
+) (*DirectUploadSlot, *ErrorRes) {
+	idObjUser, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if uploadData.Size > limits.MaxSize {
+		return nil, &ErrorRes{
+			Err: fmt.Errorf(
+				"el archivo %v excede el tamaño máximo de %v",
+				uploadData.Filename,
+				limits.MaxSizeStr,
+			),
+			StatusCode: http.StatusRequestEntityTooLarge,
+		}
+	}
+	usage, errRes := quotasService.getUserUsage(idObjUser, claims.UserType)
+	if errRes != nil {
+		return nil, errRes
+	}
+	if usage.Remaining != UNLIMITED_QUOTA && uploadData.Size > usage.Remaining {
+		return nil, quotasService.quotaError(usage)
+	}
+	// The content is not read by the server, the type comes from the
+	// extension and the storage only accepts it with that type
+	mimeType := storage.ContentType(uploadData.Filename)
+	errRes = fileTypes.checkFileType(uploadData.Filename, mimeType, claims.UserType, CHANNEL_HTTP)
+	if errRes != nil {
+		return nil, errRes
+	}
+	ext := strings.Split(uploadData.Filename, ".")
+	filename := fmt.Sprintf("%s.%s", uploadData.Title, ext[len(ext)-1])
+	parent, errRes := foldersService.getParent(uploadData.Folder, claims.ID)
+	if errRes != nil {
+		return nil, errRes
+	}
+	// With rename the name is resolved when confirming
+	if !uploadData.Rename {
+		if errRes := filesService.checkFilename(filename, idObjUser, parent); errRes != nil {
+			return nil, errRes
+		}
+	}
+
+	// The client uploads to a staging key, the file is copied out of
+	// it when confirming so the upload URL can not change it later
+	key := utils.NewStagingKey(claims.ID, uploadData.Filename)
+	urlStr, err := fileStorage.GetUploadToken(key, mimeType, uploadData.Size)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	location, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	location.RawQuery = ""
+	upload := directUploadsModel.NewModel(
+		uploadData.Title,
+		filename,
+		uploadData.Filename,
+		key,
+		location.String(),
+		mimeType,
+		idObjUser,
+		uploadData.Size,
+		parent,
+		time.Now().Add(DIRECT_UPLOAD_EXPIRATION),
+	)
+	upload.Rename = uploadData.Rename
+	inserted, err := directUploadsModel.Use().InsertOne(db.Ctx, upload)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	upload.ID = inserted.InsertedID.(primitive.ObjectID)
+	return &DirectUploadSlot{
+		Upload: upload,
+		URL:    urlStr,
+		Method: http.MethodPut,
+		Headers: map[string]string{
+			"Content-Type": mimeType,
+		},
+	}, nil
+}
+
+func (u *UploadsService) discardDirectUpload(upload *models.DirectUpload) error {
+	if err := fileStorage.DeleteFile(upload.Key); err != nil {
+		return err
+	}
+	_, err := directUploadsModel.Use().DeleteOne(db.Ctx, bson.D{{
+		Key:   "_id",
+		Value: upload.ID,
+	}})
+	return err
+}
+
+// Mark the upload as being confirmed, only one confirmation wins
+func (u *UploadsService) claimDirectUpload(upload *models.DirectUpload) *ErrorRes {
+	result, err := directUploadsModel.Use().UpdateOne(db.Ctx, bson.M{
+		"_id":          upload.ID,
+		"confirmed_at": bson.M{"$exists": false},
+	}, bson.M{
+		"$set": bson.M{
+			"confirmed_at": primitive.NewDateTimeFromTime(time.Now()),
+		},
+	})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if result.ModifiedCount != 1 {
+		return &ErrorRes{
+			Err:        errors.New("la subida ya fue confirmada"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	return nil
+}
+
+// Allow confirming the upload again after an error
+func (u *UploadsService) unclaimDirectUpload(upload *models.DirectUpload) {
+	_, err := directUploadsModel.Use().UpdateOne(db.Ctx, bson.M{
+		"_id": upload.ID,
+	}, bson.M{
+		"$unset": bson.M{
+			"confirmed_at": "",
+		},
+	})
+	if err != nil {
+		fmt.Printf("Error releasing direct upload %v: %v\n", upload.ID.Hex(), err)
+	}
+}
+
+// Read the copied file checking its type, size and checksum
+func (u *UploadsService) readDirectUpload(
+	upload *models.DirectUpload,
+	stored *storedFile,
+	userType string,
+) *ErrorRes {
+	file, err := fileStorage.GetFile(stored.Key)
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	defer file.Close()
+
+	head, mimeType, errRes := filesService.sniffFile(file, upload.OriginalFilename, userType)
+	if errRes != nil {
+		return errRes
+	}
+	hash := sha256.New()
+	hash.Write(head)
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if int64(len(head))+size != upload.Size {
+		return &ErrorRes{
+			Err:        errors.New("el archivo subido no coincide con el declarado"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	stored.Size = upload.Size
+	stored.Checksum = hex.EncodeToString(hash.Sum(nil))
+	stored.Type = mimeType
+	return nil
+}
+
+// Check the uploaded object and create the file. The upload is
+// confirmed once, the object is copied to its final key and read
+// from there, so later writes to the upload URL are ignored
+func (u *UploadsService) ConfirmDirectUpload(idUpload string, claims *Claims) (*models.File, *ErrorRes) {
+	upload, errRes := u.getDirectUpload(idUpload, claims.ID)
+	if errRes != nil {
+		return nil, errRes
+	}
+	if upload.ExpiresAt.Time().Before(time.Now()) {
+		u.discardDirectUpload(upload)
+		return nil, &ErrorRes{
+			Err:        errors.New("la subida ha expirado"),
+			StatusCode: http.StatusGone,
+		}
+	}
+	if errRes := u.claimDirectUpload(upload); errRes != nil {
+		return nil, errRes
+	}
+	size, contentType, err := fileStorage.HeadFile(upload.Key)
+	if err != nil {
+		u.unclaimDirectUpload(upload)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, &ErrorRes{
+				Err:        errors.New("el archivo aún no ha sido subido"),
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if size != upload.Size || contentType != upload.Type {
+		u.discardDirectUpload(upload)
+		return nil, &ErrorRes{
+			Err:        errors.New("el archivo subido no coincide con el declarado"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	filename, errRes := filesService.resolveFilename(
+		upload.Filename,
+		upload.User,
+		upload.Parent,
+		upload.Rename,
+	)
+	if errRes != nil {
+		u.unclaimDirectUpload(upload)
+		return nil, errRes
+	}
+	usage, errRes := quotasService.getUserUsage(upload.User, claims.UserType)
+	if errRes != nil {
+		u.unclaimDirectUpload(upload)
+		return nil, errRes
+	}
+	if usage.Remaining != UNLIMITED_QUOTA && size > usage.Remaining {
+		u.discardDirectUpload(upload)
+		return nil, quotasService.quotaError(usage)
+	}
+	// Copy
+	stored := &storedFile{
+		Key: utils.NewFileKey(claims.ID, upload.OriginalFilename),
+	}
+	stored.Location, err = fileStorage.CopyFile(upload.Key, stored.Key)
+	if err != nil {
+		u.unclaimDirectUpload(upload)
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if errRes := u.readDirectUpload(upload, stored, claims.UserType); errRes != nil {
+		fileStorage.DeleteFile(stored.Key)
+		if errRes.StatusCode == http.StatusServiceUnavailable {
+			u.unclaimDirectUpload(upload)
+		} else {
+			u.discardDirectUpload(upload)
+		}
+		return nil, errRes
+	}
+	if errRes := quotasService.consume(upload.User, stored.Size, usage); errRes != nil {
+		fileStorage.DeleteFile(stored.Key)
+		u.discardDirectUpload(upload)
+		return nil, errRes
+	}
+	// Identical contents share the same object
+	if err := blobsService.store(stored); err != nil {
+		fileStorage.DeleteFile(stored.Key)
+		quotasService.release(upload.User, stored.Size)
+		u.unclaimDirectUpload(upload)
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	fileModel, err := filesModel.NewModel(
+		filename,
+		stored.Key,
+		stored.Location,
+		upload.Title,
+		stored.Type,
+		claims.ID,
+		"private",
+	)
+	if err != nil {
+		filesService.discardStored(stored, upload.User)
+		u.discardDirectUpload(upload)
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	fileModel.Size = stored.Size
+	fileModel.Checksum = stored.Checksum
+	fileModel.Parent = upload.Parent
+	fileModel.ScanStatus = filesService.initialScanStatus()
+	newFile, errRes := filesService.uploadFileDB(fileModel, upload.Rename)
+	if errRes != nil {
+		filesService.discardStored(stored, upload.User)
+		u.unclaimDirectUpload(upload)
+		return nil, errRes
+	}
+	// The upload stays confirmed until it expires, the staging
+	// object is deleted now and again when cleaning it
+	if err := fileStorage.DeleteFile(upload.Key); err != nil {
+		fmt.Printf("Error deleting direct upload %v: %v\n", upload.ID.Hex(), err)
+	}
+	filesService.processContent(newFile.Key, newFile.Type)
+	return newFile, nil
+}
+
+func (u *UploadsService) AbortDirectUpload(idUpload, idUser string) *ErrorRes {
+	upload, errRes := u.getDirectUpload(idUpload, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	if err := u.discardDirectUpload(upload); err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return nil
+}
+
+func (u *UploadsService) cleanExpiredDirectUploads() {
+	var uploads []models.DirectUpload
+	cursor, err := directUploadsModel.Use().Find(db.Ctx, bson.D{{
+		Key: "expires_at",
+		Value: bson.M{
+			"$lt": primitive.NewDateTimeFromTime(time.Now()),
+		},
+	}})
+	if err != nil {
+		fmt.Printf("Error cleaning direct uploads: %v\n", err)
+		return
+	}
+	if err := cursor.All(db.Ctx, &uploads); err != nil {
+		fmt.Printf("Error cleaning direct uploads: %v\n", err)
+		return
+	}
+	for i := range uploads {
+		if err := u.discardDirectUpload(&uploads[i]); err != nil {
+			fmt.Printf("Error cleaning direct upload %v: %v\n", uploads[i].ID.Hex(), err)
+		}
+	}
+}
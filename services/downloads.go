@@ -0,0 +1,76 @@
+package services
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/storage"
+)
+
+// Download is a file streamed through the service
+type Download struct {
+	Content  *storage.RangeReader
+	Filename string
+	Type     string
+	ETag     string
+	ModTime  time.Time
+}
+
+func (f *FilesService) DownloadFile(idFile, variant string, claims *Claims) (*Download, *ErrorRes) {
+	file, errRes := f.getReadableFile(idFile, claims)
+	if errRes != nil {
+		return nil, errRes
+	}
+	key, errRes := f.getVariantKey(file, variant)
+	if errRes != nil {
+		return nil, errRes
+	}
+	size, contentType, err := fileStorage.HeadFile(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, &ErrorRes{
+				Err:        err,
+				StatusCode: http.StatusNotFound,
+			}
+		}
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	download := &Download{
+		Content:  storage.NewRangeReader(fileStorage, key, size),
+		Filename: file.Filename,
+		Type:     file.Type,
+		ModTime:  file.Date.Time(),
+	}
+	// The content of a key never changes, so the checksum or the
+	// key itself identify it
+	if key == file.Key && file.Checksum != "" {
+		download.ETag = fmt.Sprintf(`"%s"`, file.Checksum)
+	} else {
+		download.ETag = fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(key)))
+	}
+	if key != file.Key {
+		ext := filepath.Ext(key)
+		download.Filename = fmt.Sprintf(
+			"%s_%s%s",
+			strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename)),
+			variant,
+			ext,
+		)
+		download.Type = storage.ContentType(key)
+	}
+	if download.Type == "" || !strings.Contains(download.Type, "/") {
+		download.Type = contentType
+	}
+	if download.Type == "" {
+		download.Type = storage.ContentType(key)
+	}
+	return download, nil
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/CPU-commits/Intranet_BFiles/settings"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+)
+
+// Channels where files arrive
+const (
+	CHANNEL_HTTP             = "http"
+	CHANNEL_UPLOAD_IMAGE     = "upload_image"
+	CHANNEL_UPLOAD_CLASSROOM = "upload_files_classroom"
+)
+
+type fileTypesPolicy struct {
+	*utils.FileTypesPolicy
+}
+
+var fileTypes = newFileTypesPolicy()
+
+func newFileTypesPolicy() *fileTypesPolicy {
+	settingsData := settings.GetSettings()
+	if settingsData.FILE_TYPES_POLICY == "" {
+		return &fileTypesPolicy{utils.DefaultFileTypesPolicy()}
+	}
+	data, err := os.ReadFile(settingsData.FILE_TYPES_POLICY)
+	if err != nil {
+		panic(err)
+	}
+	policy, err := utils.ParseFileTypesPolicy(data)
+	if err != nil {
+		panic(fmt.Sprintf("invalid file types policy: %v", err))
+	}
+	return &fileTypesPolicy{policy}
+}
+
+func (p *fileTypesPolicy) checkFileType(filename, mimeType, userType, channel string) *ErrorRes {
+	if err := p.Check(filename, mimeType, userType, channel); err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusUnsupportedMediaType,
+		}
+	}
+	return nil
+}
@@ -1,16 +1,22 @@
 package services
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"mime"
-	"mime/multipart"
+	"io"
 	"net/http"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/CPU-commits/Intranet_BFiles/db"
 	"github.com/CPU-commits/Intranet_BFiles/forms"
 	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/previews"
 	"github.com/CPU-commits/Intranet_BFiles/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -25,46 +31,134 @@ func (f *FilesService) getMatchFile(idUser primitive.ObjectID) bson.D {
 	return bson.D{{
 		Key: "$match",
 		Value: bson.M{
-			"user": idUser,
+			"user":   idUser,
+			"status": true,
 		},
 	}}
 }
 
-func (f *FilesService) GetFiles(permissions string, idUser string) ([]models.File, *ErrorRes) {
+const (
+	FILES_DEFAULT_LIMIT = 20
+)
+
+func (f *FilesService) getFilesFilters(query forms.FilesQueryForm) bson.M {
+	match := bson.M{}
+	if query.Permissions != "any" && query.Permissions != "" {
+		match["permissions"] = query.Permissions
+	}
+	if query.Search != "" {
+		search := primitive.Regex{
+			Pattern: regexp.QuoteMeta(query.Search),
+			Options: "i",
+		}
+		match["$or"] = bson.A{
+			bson.M{"title": search},
+			bson.M{"filename": search},
+		}
+	}
+	if query.Type != "" {
+		match["type"] = primitive.Regex{
+			Pattern: fmt.Sprintf("^%s/", query.Type),
+		}
+	}
+	if query.From != 0 || query.To != 0 {
+		date := bson.M{}
+		if query.From != 0 {
+			date["$gte"] = primitive.NewDateTimeFromTime(time.Unix(query.From, 0))
+		}
+		if query.To != 0 {
+			date["$lte"] = primitive.NewDateTimeFromTime(time.Unix(query.To, 0))
+		}
+		match["date"] = date
+	}
+	if tags := normalizeTags(query.Tags); len(tags) > 0 {
+		match["tags"] = bson.M{"$all": tags}
+	}
+	return match
+}
+
+func (f *FilesService) GetFiles(query forms.FilesQueryForm, idUser string) ([]models.File, int, *ErrorRes) {
 	idObjUser, err := primitive.ObjectIDFromHex(idUser)
 	if err != nil {
-		return nil, &ErrorRes{
+		return nil, 0, &ErrorRes{
 			Err:        err,
 			StatusCode: http.StatusBadRequest,
 		}
 	}
+	// Without page nor limit every file is returned, as before the
+	// pagination
+	paged := query.Page > 0 || query.Limit > 0
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.Limit == 0 {
+		query.Limit = FILES_DEFAULT_LIMIT
+	}
+	filesStages := bson.A{
+		bson.M{"$project": bson.M{"versions": 0, "content": 0}},
+	}
+	if paged {
+		filesStages = append(bson.A{
+			bson.M{"$skip": (query.Page - 1) * query.Limit},
+			bson.M{"$limit": query.Limit},
+		}, filesStages...)
+	}
+	sortField := "date"
+	if query.Sort != "" {
+		sortField = query.Sort
+	}
+	order := -1
+	if query.Order == "asc" {
+		order = 1
+	}
+
 	pipeline := mongo.Pipeline{
 		f.getMatchFile(idObjUser),
-	}
-	if permissions != "any" {
-		pipeline = append(pipeline, bson.D{{
-			Key: "$match",
+		bson.D{{
+			Key:   "$match",
+			Value: f.getFilesFilters(query),
+		}},
+		bson.D{{
+			Key: "$sort",
+			Value: bson.D{
+				{Key: sortField, Value: order},
+				{Key: "_id", Value: order},
+			},
+		}},
+		bson.D{{
+			Key: "$facet",
 			Value: bson.M{
-				"permissions": permissions,
+				"files": filesStages,
+				"total": bson.A{
+					bson.M{"$count": "total"},
+				},
 			},
-		}})
+		}},
+	}
+	var result []struct {
+		Files []models.File `bson:"files"`
+		Total []struct {
+			Total int `bson:"total"`
+		} `bson:"total"`
 	}
-	var files []models.File
 
 	cursor, err := filesModel.Use().Aggregate(db.Ctx, pipeline)
 	if err != nil {
-		return nil, &ErrorRes{
+		return nil, 0, &ErrorRes{
 			Err:        err,
 			StatusCode: http.StatusServiceUnavailable,
 		}
 	}
-	if err := cursor.All(db.Ctx, &files); err != nil {
-		return nil, &ErrorRes{
+	if err := cursor.All(db.Ctx, &result); err != nil {
+		return nil, 0, &ErrorRes{
 			Err:        err,
 			StatusCode: http.StatusServiceUnavailable,
 		}
 	}
-	return files, nil
+	if len(result) == 0 || len(result[0].Total) == 0 {
+		return []models.File{}, 0, nil
+	}
+	return result[0].Files, result[0].Total[0].Total, nil
 }
 
 func (f *FilesService) getFile(idFile string) (*models.File, *ErrorRes) {
@@ -95,30 +189,72 @@ func (f *FilesService) getFile(idFile string) (*models.File, *ErrorRes) {
 	return file, nil
 }
 
-func (f *FilesService) GetFile(idFile, idUser string) (string, *ErrorRes) {
-	file, err := f.getFile(idFile)
-	if err != nil {
-		return "", err
-	}
+func (f *FilesService) checkReadAccess(file *models.File, claims *Claims) *ErrorRes {
 	if !file.Status {
-		return "", &ErrorRes{
+		return &ErrorRes{
 			Err:        errors.New("el archivo está eliminado"),
 			StatusCode: http.StatusConflict,
 		}
 	}
-	if idUser != file.User.Hex() && file.Permissions == "private" {
-		return "", &ErrorRes{
-			Err:        errors.New("el archivo es privado"),
-			StatusCode: http.StatusUnauthorized,
-		}
+	if claims.ID == file.User.Hex() || file.Permissions == "public" {
+		return nil
+	}
+	if file.IsSharedWith(claims.ID, claims.UserType, false) {
+		return nil
 	}
 	if file.Permissions == "public_classroom" {
-		return "", &ErrorRes{
-			Err:        errors.New("no se puede determinar si el archivo pertenece a una aula virtual"),
-			StatusCode: http.StatusBadRequest,
+		if file.Classroom.IsZero() {
+			return &ErrorRes{
+				Err:        errors.New("no se puede determinar si el archivo pertenece a una aula virtual"),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		isMember, err := isUserInClassroom(file.Classroom, claims)
+		if err != nil {
+			return &ErrorRes{
+				Err:        err,
+				StatusCode: http.StatusServiceUnavailable,
+			}
 		}
+		if isMember {
+			return nil
+		}
+		return &ErrorRes{
+			Err:        errors.New("el archivo pertenece a una aula virtual a la que no pertenece"),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	return &ErrorRes{
+		Err:        errors.New("el archivo es privado"),
+		StatusCode: http.StatusUnauthorized,
+	}
+}
+
+// File the user can read and download
+func (f *FilesService) getReadableFile(idFile string, claims *Claims) (*models.File, *ErrorRes) {
+	file, err := f.getFile(idFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.checkReadAccess(file, claims); err != nil {
+		return nil, err
+	}
+	if err := f.checkScanStatus(file.ScanStatus); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (f *FilesService) GetFile(idFile, variant string, claims *Claims) (string, *ErrorRes) {
+	file, err := f.getReadableFile(idFile, claims)
+	if err != nil {
+		return "", err
 	}
-	urlStr, errRes := aws.GetFileToken(file.Key)
+	key, err := f.getVariantKey(file, variant)
+	if err != nil {
+		return "", err
+	}
+	urlStr, errRes := fileStorage.GetFileToken(key)
 	if errRes != nil {
 		return "", &ErrorRes{
 			Err:        errRes,
@@ -128,93 +264,351 @@ func (f *FilesService) GetFile(idFile, idUser string) (string, *ErrorRes) {
 	return urlStr, nil
 }
 
-func (f *FilesService) uploadFileDB(
-	filename,
-	key,
-	url,
-	title,
-	typeFile,
-	idUser string,
-) (*models.File, *ErrorRes) {
-	fileModel, err := filesModel.NewModel(filename, key, url, title, typeFile, idUser, "private")
+// Times the insert is retried with another name, when the name
+// is taken meanwhile by another upload
+const RENAME_ATTEMPTS = 3
+
+var errFilenameTaken = errors.New("ya tiene un archivo con este mismo nombre")
+
+// The unique index on the owner, folder and filename rejects the
+// uploads that passed the check at the same time
+func (f *FilesService) uploadFileDB(fileModel *models.File, rename bool) (*models.File, *ErrorRes) {
+	for attempt := 1; ; attempt++ {
+		idFile, err := filesModel.Use().InsertOne(db.Ctx, fileModel)
+		if err == nil {
+			fileModel.ID = idFile.InsertedID.(primitive.ObjectID)
+			return fileModel, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) || !rename || attempt == RENAME_ATTEMPTS {
+			return nil, f.filenameError(err)
+		}
+		filename, errRes := f.freeFilename(fileModel.Filename, fileModel.User, fileModel.Parent)
+		if errRes != nil {
+			return nil, errRes
+		}
+		fileModel.Filename = filename
+	}
+}
+
+// Errors of the writes that change the name or the folder of a file
+func (f *FilesService) filenameError(err error) *ErrorRes {
+	if mongo.IsDuplicateKeyError(err) {
+		return &ErrorRes{
+			Err:        errFilenameTaken,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return &ErrorRes{
+		Err:        err,
+		StatusCode: http.StatusServiceUnavailable,
+	}
+}
+
+func filenameFilter(idUser, parent primitive.ObjectID) bson.M {
+	return bson.M{
+		"user":   idUser,
+		"status": true,
+		"parent": foldersService.getMatchParent(parent),
+	}
+}
+
+// Filenames are unique in each folder of the owner
+func (f *FilesService) checkFilename(filename string, idUser, parent primitive.ObjectID) *ErrorRes {
+	filter := filenameFilter(idUser, parent)
+	filter["filename"] = filename
+	count, err := filesModel.Use().CountDocuments(db.Ctx, filter)
 	if err != nil {
-		return nil, &ErrorRes{
+		return &ErrorRes{
 			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if count > 0 {
+		return &ErrorRes{
+			Err:        errFilenameTaken,
 			StatusCode: http.StatusBadRequest,
 		}
 	}
-	idFile, err := filesModel.Use().InsertOne(db.Ctx, fileModel)
+	return nil
+}
+
+// Number added to a repeated filename, "name (n)"
+var numberedName = regexp.MustCompile(`^(.+) \(\d+\)$`)
+
+// The filename if it is free, else the first free "name (n).ext"
+func (f *FilesService) freeFilename(filename string, idUser, parent primitive.ObjectID) (string, *ErrorRes) {
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	// A renamed file is numbered from the original name
+	if match := numberedName.FindStringSubmatch(base); match != nil {
+		base = match[1]
+	}
+	filter := filenameFilter(idUser, parent)
+	filter["filename"] = primitive.Regex{
+		Pattern: fmt.Sprintf(`^%s( \(\d+\))?%s$`, regexp.QuoteMeta(base), regexp.QuoteMeta(ext)),
+	}
+	names, err := filesModel.Use().Distinct(db.Ctx, "filename", filter)
 	if err != nil {
-		return nil, &ErrorRes{
+		return "", &ErrorRes{
 			Err:        err,
 			StatusCode: http.StatusServiceUnavailable,
 		}
 	}
+	taken := make(map[string]bool)
+	for _, name := range names {
+		if name, ok := name.(string); ok {
+			taken[name] = true
+		}
+	}
+	if !taken[filename] {
+		return filename, nil
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if !taken[candidate] {
+			return candidate, nil
+		}
+	}
+}
 
-	return &models.File{
-		ID:          idFile.InsertedID.(primitive.ObjectID),
-		Filename:    filename,
-		Key:         key,
-		URL:         url,
-		Title:       title,
-		Type:        typeFile,
-		User:        fileModel.User,
-		Status:      fileModel.Status,
-		Permissions: fileModel.Permissions,
-		Date:        fileModel.Date,
-	}, nil
+// With rename a taken filename gets a number instead of failing
+func (f *FilesService) resolveFilename(
+	filename string,
+	idUser,
+	parent primitive.ObjectID,
+	rename bool,
+) (string, *ErrorRes) {
+	if rename {
+		return f.freeFilename(filename, idUser, parent)
+	}
+	if errRes := f.checkFilename(filename, idUser, parent); errRes != nil {
+		return "", errRes
+	}
+	return filename, nil
 }
 
-func (f *FilesService) UploadFile(
-	fileData forms.FileForm,
-	file *multipart.FileHeader,
-	idUser string,
-) (*models.File, *ErrorRes) {
-	ext := strings.Split(file.Filename, ".")
-	filename := fmt.Sprintf("%s.%s", fileData.Title, ext[len(ext)-1])
-	// Check if exists
-	var fileCheck *models.File
-	cursor := filesModel.Use().FindOne(db.Ctx, bson.D{{
-		Key:   "filename",
-		Value: filename,
-	}})
-	if err := cursor.Decode(&fileCheck); err != nil && err.Error() != db.NO_SINGLE_DOCUMENT {
-		return nil, &ErrorRes{
+type storedFile struct {
+	Location string
+	Key      string
+	Size     int64
+	Checksum string
+	Type     string
+}
+
+// Sniff the type from the first bytes of the file and check it is
+// allowed, returns the bytes read
+func (f *FilesService) sniffFile(file io.Reader, originalFilename, userType string) ([]byte, string, *ErrorRes) {
+	head := make([]byte, utils.SNIFF_SIZE)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, "", &ErrorRes{
 			Err:        err,
-			StatusCode: http.StatusServiceUnavailable,
+			StatusCode: http.StatusBadRequest,
 		}
 	}
-	if fileCheck != nil {
-		return nil, &ErrorRes{
-			Err:        errors.New("ya tiene un archivo con este mismo nombre"),
+	head = head[:n]
+	mimeType, err := utils.ResolveMimeType(filepath.Ext(originalFilename), head)
+	if err != nil {
+		return nil, "", &ErrorRes{
+			Err:        err,
 			StatusCode: http.StatusBadRequest,
 		}
 	}
-	// Upload file AWS S3
-	out, key, err := aws.UploadFile(file, idUser)
+	errRes := fileTypes.checkFileType(originalFilename, mimeType, userType, CHANNEL_HTTP)
+	if errRes != nil {
+		return nil, "", errRes
+	}
+	return head, mimeType, nil
+}
+
+// Upload to storage enforcing the allowed types, the max size of
+// the file and the remaining quota of the owner while streaming,
+// the used space of the owner is increased on success
+func (f *FilesService) uploadToStorage(
+	file io.Reader,
+	originalFilename string,
+	uploader *Claims,
+	idOwner primitive.ObjectID,
+	ownerType string,
+	limits *utils.FileLimits,
+) (*storedFile, *ErrorRes) {
+	usage, errRes := quotasService.getUserUsage(idOwner, ownerType)
+	if errRes != nil {
+		return nil, errRes
+	}
+	maxSize := limits.MaxSize
+	if usage.Remaining != UNLIMITED_QUOTA && usage.Remaining < maxSize {
+		maxSize = usage.Remaining
+	}
+	// The first bytes are joined again to the body before uploading
+	head, mimeType, errRes := f.sniffFile(file, originalFilename, uploader.UserType)
+	if errRes != nil {
+		return nil, errRes
+	}
+	hash := sha256.New()
+	body := io.TeeReader(io.MultiReader(bytes.NewReader(head), file), hash)
+
+	limitedFile := utils.NewLimitedReader(body, maxSize)
+	location, key, err := fileStorage.UploadFile(limitedFile, originalFilename, uploader.ID)
+	if limitedFile.Exceeded() {
+		if err == nil {
+			fileStorage.DeleteFile(key)
+		}
+		if maxSize < limits.MaxSize {
+			return nil, quotasService.quotaError(usage)
+		}
+		return nil, &ErrorRes{
+			Err: fmt.Errorf(
+				"el archivo %v excede el tamaño máximo de %v",
+				originalFilename,
+				limits.MaxSizeStr,
+			),
+			StatusCode: http.StatusRequestEntityTooLarge,
+		}
+	}
 	if err != nil {
 		return nil, &ErrorRes{
 			Err:        err,
 			StatusCode: http.StatusServiceUnavailable,
 		}
 	}
-	// Upload db
-	var mimeFile string
-	extFile := "." + ext[len(ext)-1]
-	if utils.IsCodeFile(extFile) {
-		mimeFile = utils.GetCodeFileMime(extFile)
-	} else {
-		mimeFile = mime.TypeByExtension(extFile)
+	if errRes := quotasService.consume(idOwner, limitedFile.Size(), usage); errRes != nil {
+		fileStorage.DeleteFile(key)
+		return nil, errRes
+	}
+	stored := &storedFile{
+		Location: location,
+		Key:      key,
+		Size:     limitedFile.Size(),
+		Checksum: hex.EncodeToString(hash.Sum(nil)),
+		Type:     mimeType,
+	}
+	// Identical contents share the same object
+	if err := blobsService.store(stored); err != nil {
+		fileStorage.DeleteFile(key)
+		quotasService.release(idOwner, stored.Size)
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
 	}
+	return stored, nil
+}
+
+// Background jobs over a new content, with scanning the derived
+// files wait for a clean result
+func (f *FilesService) processContent(key, mimeType string) {
+	if fileScanner == nil {
+		f.queueDerived(key, mimeType)
+		return
+	}
+	f.queueScan(key, mimeType)
+}
 
-	return f.uploadFileDB(
+// Generate the files derived from the content
+func (f *FilesService) queueDerived(key, mimeType string) {
+	f.queueVariants(key, mimeType)
+	f.queuePreview(key)
+	f.queueText(key)
+}
+
+// Delete the files derived from the content of the key
+func (f *FilesService) deleteDerived(key string) {
+	for _, variant := range imageVariants {
+		for _, ext := range []string{".jpg", ".png"} {
+			fileStorage.DeleteFile(variantKey(key, variant.Name, ext))
+		}
+	}
+	if hasPreview(key) {
+		fileStorage.DeleteFile(previewKey(key, 1, ".txt"))
+		for page := 1; page <= previews.PREVIEW_PAGES; page++ {
+			fileStorage.DeleteFile(previewKey(key, page, ".png"))
+		}
+	}
+}
+
+// Undo uploadToStorage when the file could not be saved
+func (f *FilesService) discardStored(stored *storedFile, idOwner primitive.ObjectID) {
+	blobsService.release(stored.Key)
+	quotasService.release(idOwner, stored.Size)
+}
+
+func (f *FilesService) UploadFile(
+	fileData forms.FileForm,
+	originalFilename string,
+	file io.Reader,
+	limits *utils.FileLimits,
+	claims *Claims,
+) (*models.File, *ErrorRes) {
+	idObjUser, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	ext := strings.Split(originalFilename, ".")
+	filename := fmt.Sprintf("%s.%s", fileData.Title, ext[len(ext)-1])
+	parent, errRes := foldersService.getParent(fileData.Folder, claims.ID)
+	if errRes != nil {
+		return nil, errRes
+	}
+	// Check if exists
+	filename, errRes = f.resolveFilename(filename, idObjUser, parent, fileData.Rename)
+	if errRes != nil {
+		return nil, errRes
+	}
+	// Upload file to storage
+	stored, errRes := f.uploadToStorage(
+		file,
+		originalFilename,
+		claims,
+		idObjUser,
+		claims.UserType,
+		limits,
+	)
+	if errRes != nil {
+		return nil, errRes
+	}
+	// Upload db
+	fileModel, err := filesModel.NewModel(
 		filename,
-		key,
-		out.Location,
+		stored.Key,
+		stored.Location,
 		fileData.Title,
-		mimeFile,
-		idUser,
+		stored.Type,
+		claims.ID,
+		"private",
 	)
+	if err != nil {
+		f.discardStored(stored, idObjUser)
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	fileModel.Size = stored.Size
+	fileModel.Checksum = stored.Checksum
+	fileModel.Parent = parent
+	fileModel.ScanStatus = f.initialScanStatus()
+	newFile, errRes := f.uploadFileDB(fileModel, fileData.Rename)
+	if errRes != nil {
+		f.discardStored(stored, idObjUser)
+		return nil, errRes
+	}
+	f.processContent(newFile.Key, newFile.Type)
+	return newFile, nil
+}
+
+// Undo UploadFile when the request turned out to be invalid
+func (f *FilesService) DiscardFile(file *models.File) *ErrorRes {
+	if err := f.purgeFile(file); err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return nil
 }
 
 func (f *FilesService) ChangePermissions(idUser, idFile, permissions string) *ErrorRes {
@@ -282,23 +676,20 @@ func (f *FilesService) DeleteFile(idFile, idUser string) *ErrorRes {
 			StatusCode: http.StatusUnauthorized,
 		}
 	}
-	err = filesModel.Transaction(func() error {
-		_, err = filesModel.Use().UpdateByID(db.Ctx, idObjFile, bson.D{{
-			Key: "$set",
-			Value: bson.M{
-				"status": false,
-			},
-		}})
-		if err != nil {
-			return err
-		}
-
-		err = aws.DeleteFile(file.Key)
-		if err != nil {
-			return err
+	if !file.Status {
+		return &ErrorRes{
+			Err:        errors.New("el archivo ya está en la papelera"),
+			StatusCode: http.StatusConflict,
 		}
-		return nil
-	})
+	}
+	// Move to trash, the purger deletes the content later
+	_, err = filesModel.Use().UpdateByID(db.Ctx, idObjFile, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"status":     false,
+			"deleted_at": primitive.NewDateTimeFromTime(time.Now()),
+		},
+	}})
 	if err != nil {
 		return &ErrorRes{
 			Err:        err,
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const BATCH_UPLOAD_CONCURRENCY = 3
+
+// BatchFile is one file of a batch upload
+type BatchFile struct {
+	Data     forms.FileForm
+	Filename string
+	Content  io.Reader
+}
+
+// BatchResult is the file created or the error of one file of the batch
+type BatchResult struct {
+	Filename string
+	File     *models.File
+	Err      *ErrorRes
+}
+
+// Upload every file of the batch, at most BATCH_UPLOAD_CONCURRENCY
+// at the same time. A file failing does not stop the others, the
+// results keep the order of the batch
+func (f *FilesService) UploadFiles(
+	batch []BatchFile,
+	limits *utils.FileLimits,
+	claims *Claims,
+) []BatchResult {
+	results := make([]BatchResult, len(batch))
+	// Two files of the batch with the same name would both pass
+	// the filename check
+	filenames := make(map[string]bool)
+	queue := make(chan struct{}, BATCH_UPLOAD_CONCURRENCY)
+	var wg sync.WaitGroup
+	for i, file := range batch {
+		results[i].Filename = file.Filename
+		if err := binding.Validator.ValidateStruct(&file.Data); err != nil {
+			results[i].Err = &ErrorRes{
+				Err:        err,
+				StatusCode: http.StatusBadRequest,
+			}
+			continue
+		}
+		ext := strings.Split(file.Filename, ".")
+		filename := fmt.Sprintf("%s.%s", file.Data.Title, ext[len(ext)-1])
+		if filenames[filename] && !file.Data.Rename {
+			results[i].Err = &ErrorRes{
+				Err:        fmt.Errorf("el archivo %v está repetido en la subida", filename),
+				StatusCode: http.StatusConflict,
+			}
+			continue
+		}
+		filenames[filename] = true
+
+		wg.Add(1)
+		queue <- struct{}{}
+		go func(i int, file BatchFile) {
+			defer func() {
+				<-queue
+				wg.Done()
+			}()
+			results[i].File, results[i].Err = f.UploadFile(
+				file.Data,
+				file.Filename,
+				file.Content,
+				limits,
+				claims,
+			)
+		}(i, file)
+	}
+	wg.Wait()
+	return results
+}
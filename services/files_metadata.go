@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TagCount is a tag of the user and the number of files with it
+type TagCount struct {
+	Tag   string `json:"tag" bson:"_id"`
+	Count int    `json:"count" bson:"count"`
+}
+
+// Tags are compared in lower case, without repeating them
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// Change the title, the description or the tags. A new title
+// renames the file, so the filename is checked again
+func (f *FilesService) UpdateFile(
+	idFile string,
+	fileData forms.UpdateFileForm,
+	claims *Claims,
+) (*models.File, *ErrorRes) {
+	file, errRes := f.getWritableFile(idFile, claims)
+	if errRes != nil {
+		return nil, errRes
+	}
+	set := bson.M{}
+	unset := bson.M{}
+	if fileData.Title != nil && *fileData.Title != file.Title {
+		ext := strings.Split(file.Filename, ".")
+		filename := fmt.Sprintf("%s.%s", *fileData.Title, ext[len(ext)-1])
+		if filename != file.Filename {
+			if errRes := f.checkFilename(filename, file.User, file.Parent); errRes != nil {
+				return nil, errRes
+			}
+		}
+		set["title"] = *fileData.Title
+		set["filename"] = filename
+		file.Title = *fileData.Title
+		file.Filename = filename
+	}
+	if fileData.Description != nil {
+		description := strings.TrimSpace(*fileData.Description)
+		if description == "" {
+			unset["description"] = ""
+		} else {
+			set["description"] = description
+		}
+		file.Description = description
+	}
+	if fileData.Tags != nil {
+		tags := normalizeTags(*fileData.Tags)
+		if len(tags) == 0 {
+			unset["tags"] = ""
+		} else {
+			set["tags"] = tags
+		}
+		file.Tags = tags
+	}
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+	if len(update) == 0 {
+		return file, nil
+	}
+	_, err := filesModel.Use().UpdateByID(db.Ctx, file.ID, update)
+	if err != nil {
+		return nil, f.filenameError(err)
+	}
+	return file, nil
+}
+
+// Tags of the files of the user, the most used first
+func (f *FilesService) GetTags(idUser string) ([]TagCount, *ErrorRes) {
+	idObjUser, err := primitive.ObjectIDFromHex(idUser)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	pipeline := mongo.Pipeline{
+		f.getMatchFile(idObjUser),
+		bson.D{{
+			Key:   "$unwind",
+			Value: "$tags",
+		}},
+		bson.D{{
+			Key: "$group",
+			Value: bson.M{
+				"_id":   "$tags",
+				"count": bson.M{"$sum": 1},
+			},
+		}},
+		bson.D{{
+			Key: "$sort",
+			Value: bson.D{
+				{Key: "count", Value: -1},
+				{Key: "_id", Value: 1},
+			},
+		}},
+	}
+	tags := []TagCount{}
+	cursor, err := filesModel.Use().Aggregate(db.Ctx, pipeline)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if err := cursor.All(db.Ctx, &tags); err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return tags, nil
+}
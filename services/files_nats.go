@@ -3,14 +3,17 @@ package services
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"sync"
 
 	"github.com/CPU-commits/Intranet_BFiles/db"
 	"github.com/CPU-commits/Intranet_BFiles/models"
 	"github.com/CPU-commits/Intranet_BFiles/res"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
 	"github.com/nats-io/nats.go"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/sync/errgroup"
 )
 
 func uploadImage() {
@@ -28,6 +31,19 @@ func uploadImage() {
 		}
 		file := strings.Split(key, "/")
 		filename := file[len(file)-1]
+		// The image is already stored, a forbidden type is removed
+		mimeType := utils.GetMimeType(filepath.Ext(filename))
+		errRes := fileTypes.checkFileType(
+			filename,
+			mimeType,
+			"",
+			CHANNEL_UPLOAD_IMAGE,
+		)
+		if errRes != nil {
+			fmt.Printf("Image %v rejected: %v\n", key, errRes.Err)
+			fileStorage.DeleteFile(key)
+			return
+		}
 		fileModel, _ := filesModel.NewModel(
 			filename,
 			key,
@@ -37,10 +53,12 @@ func uploadImage() {
 			"",
 			"public",
 		)
+		fileModel.ScanStatus = filesService.initialScanStatus()
 		insertedId, err := filesModel.Use().InsertOne(db.Ctx, fileModel)
 		if err != nil {
 			return
 		}
+		filesService.processContent(key, mimeType)
 		// Inserted
 		fileInserted := &models.File{
 			ID:          insertedId.InsertedID.(primitive.ObjectID),
@@ -53,6 +71,7 @@ func uploadImage() {
 			Status:      fileModel.Status,
 			Permissions: fileModel.Permissions,
 			Date:        fileModel.Date,
+			ScanStatus:  fileModel.ScanStatus,
 		}
 		jsonData, _ := json.Marshal(res.WrapFileRes(*fileInserted))
 		m.Respond(jsonData)
@@ -71,7 +90,7 @@ func deleteImage() {
 			keyFromNest := data["data"].(string)
 			key = keyFromNest
 		}
-		if err := aws.DeleteFile(key); err != nil {
+		if err := fileStorage.DeleteFile(key); err != nil {
 			return
 		}
 		m.Respond([]byte("success"))
@@ -93,8 +112,7 @@ func deleteAWSFile() {
 		if errRes != nil {
 			return
 		}
-		errRes = filesService.DeleteFile(file.ID.Hex(), file.User.Hex())
-		if errRes != nil {
+		if err := filesService.purgeFile(file); err != nil {
 			return
 		}
 	})
@@ -108,6 +126,17 @@ func uploadFileClassroom() {
 		if err != nil {
 			return
 		}
+		errRes := fileTypes.checkFileType(
+			file.Filename,
+			file.Mimetype,
+			"",
+			CHANNEL_UPLOAD_CLASSROOM,
+		)
+		if errRes != nil {
+			fmt.Printf("File %v rejected: %v\n", file.Key, errRes.Err)
+			fileStorage.DeleteFile(file.Key)
+			return
+		}
 		fileModel, _ := filesModel.NewModel(
 			file.Filename,
 			file.Key,
@@ -117,10 +146,19 @@ func uploadFileClassroom() {
 			"",
 			"public_classroom",
 		)
+		if file.Classroom != "" {
+			idObjClassroom, err := primitive.ObjectIDFromHex(file.Classroom)
+			if err != nil {
+				return
+			}
+			fileModel.Classroom = idObjClassroom
+		}
+		fileModel.ScanStatus = filesService.initialScanStatus()
 		insertedId, err := filesModel.Use().InsertOne(db.Ctx, fileModel)
 		if err != nil {
 			return
 		}
+		filesService.processContent(file.Key, file.Mimetype)
 		fileData, errRes := filesService.getFile(
 			insertedId.InsertedID.(primitive.ObjectID).Hex(),
 		)
@@ -134,7 +172,7 @@ func uploadFileClassroom() {
 
 func getAWSTokenAccess() {
 	nats_service.Queue("get_aws_token_access", func(m *nats.Msg) {
-		// Recovery if close channel
+		// Recovery if the data is malformed
 		defer func() {
 			recovery := recover()
 			if recovery != nil {
@@ -143,6 +181,7 @@ func getAWSTokenAccess() {
 		}()
 
 		var filesKeys []string
+		var variant string
 
 		data, err := nats_service.DecodeDataNest(m.Data)
 		if err != nil {
@@ -150,37 +189,46 @@ func getAWSTokenAccess() {
 			if err != nil {
 				return
 			}
+		} else if keysFromNest, ok := data["keys"].([]interface{}); ok {
+			// {"keys": [...], "variant": "thumbnail"}
+			variant, _ = data["variant"].(string)
+			for _, key := range keysFromNest {
+				filesKeys = append(filesKeys, fmt.Sprintf("%v", key))
+			}
 		} else {
 			filesKeysFromNest := data["data"].([]interface{})
 			for _, key := range filesKeysFromNest {
 				filesKeys = append(filesKeys, fmt.Sprintf("%v", key))
 			}
 		}
+		// The reply keeps the order of the keys, a key not scanned as
+		// clean or without token gets "" and the others are still sent
 		tokensUrls := make([]string, len(filesKeys))
 
-		var errRes error
-		var wg sync.WaitGroup
-		c := make(chan (int), 10)
+		var group errgroup.Group
+		group.SetLimit(10)
 		for i, token := range filesKeys {
-			wg.Add(1)
-			c <- 1
-			go func(index int, token string, wg *sync.WaitGroup, errRes *error) {
-				defer wg.Done()
-
-				tokenUrl, err := aws.GetFileToken(token)
+			index, token := i, token
+			group.Go(func() error {
+				if errScan := filesService.checkKeyScanStatus(token); errScan != nil {
+					fmt.Printf("Token of %v not issued: %v\n", token, errScan.Err)
+					return nil
+				}
+				key, errVariant := filesService.getKeyVariant(token, variant)
+				if errVariant != nil {
+					fmt.Printf("Token of %v not issued: %v\n", token, errVariant.Err)
+					return nil
+				}
+				tokenUrl, err := fileStorage.GetFileToken(key)
 				if err != nil {
-					*errRes = err
-					close(c)
-					return
+					fmt.Printf("Token of %v not issued: %v\n", token, err)
+					return nil
 				}
 				tokensUrls[index] = tokenUrl
-				<-c
-			}(i, token, &wg, &errRes)
-		}
-		wg.Wait()
-		if errRes != nil {
-			return
+				return nil
+			})
 		}
+		group.Wait()
 
 		jsonData, _ := json.Marshal(tokensUrls)
 		m.Respond(jsonData)
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (f *FilesService) getUserFile(idFile, idUser string) (*models.File, *ErrorRes) {
+	file, errRes := f.getFile(idFile)
+	if errRes != nil {
+		return nil, errRes
+	}
+	if file.User.Hex() != idUser {
+		return nil, &ErrorRes{
+			Err:        errors.New("el archivo le pertenece a otro usuario"),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	if !file.Status {
+		return nil, &ErrorRes{
+			Err:        errors.New("el archivo está eliminado"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	return file, nil
+}
+
+// The owner and the users shared with write access can
+// change the content of the file
+func (f *FilesService) getWritableFile(idFile string, claims *Claims) (*models.File, *ErrorRes) {
+	file, errRes := f.getFile(idFile)
+	if errRes != nil {
+		return nil, errRes
+	}
+	if file.User.Hex() != claims.ID && !file.IsSharedWith(claims.ID, claims.UserType, true) {
+		return nil, &ErrorRes{
+			Err:        errors.New("no tiene permisos para modificar el archivo"),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	if !file.Status {
+		return nil, &ErrorRes{
+			Err:        errors.New("el archivo está eliminado"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	return file, nil
+}
+
+func (f *FilesService) currentAsVersion(file *models.File) models.FileVersion {
+	uploadedBy := file.User
+	if !file.UploadedBy.IsZero() {
+		uploadedBy = file.UploadedBy
+	}
+	return models.FileVersion{
+		Version:    file.CurrentVersion(),
+		Filename:   file.Filename,
+		Key:        file.Key,
+		URL:        file.URL,
+		Type:       file.Type,
+		Size:       file.Size,
+		Checksum:   file.Checksum,
+		ScanStatus: file.ScanStatus,
+		User:       uploadedBy,
+		Date:       file.Date,
+	}
+}
+
+// Replace the current content, the filter on the key makes the
+// update fail if another request changed the file meanwhile
+func (f *FilesService) setCurrentVersion(
+	file *models.File,
+	current models.FileVersion,
+	versions []models.FileVersion,
+) *ErrorRes {
+	set := bson.M{
+		"filename":    current.Filename,
+		"key":         current.Key,
+		"url":         current.URL,
+		"type":        current.Type,
+		"size":        current.Size,
+		"checksum":    current.Checksum,
+		"date":        current.Date,
+		"version":     current.Version,
+		"versions":    versions,
+		"uploaded_by": current.User,
+	}
+	// The derived files belong to the replaced content
+	unset := bson.M{
+		"variants":       "",
+		"previews":       "",
+		"preview_status": "",
+		"content":        "",
+	}
+	// Contents uploaded while scanning was disabled have no status
+	if current.ScanStatus == "" {
+		unset["scan_status"] = ""
+	} else {
+		set["scan_status"] = current.ScanStatus
+	}
+	update := bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$unset", Value: unset},
+	}
+	result, err := filesModel.Use().UpdateOne(db.Ctx, bson.D{
+		{Key: "_id", Value: file.ID},
+		{Key: "key", Value: file.Key},
+	}, update)
+	if err != nil {
+		return f.filenameError(err)
+	}
+	if result.MatchedCount == 0 {
+		return &ErrorRes{
+			Err:        errors.New("el archivo fue modificado por otra petición"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	return nil
+}
+
+func (f *FilesService) UploadVersion(
+	idFile string,
+	claims *Claims,
+	originalFilename string,
+	file io.Reader,
+	limits *utils.FileLimits,
+) (*models.File, *ErrorRes) {
+	fileData, errRes := f.getWritableFile(idFile, claims)
+	if errRes != nil {
+		return nil, errRes
+	}
+	idObjUser, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	ext := strings.Split(originalFilename, ".")
+	filename := fmt.Sprintf("%s.%s", fileData.Title, ext[len(ext)-1])
+	if filename != fileData.Filename {
+		if errRes := f.checkFilename(filename, fileData.User, fileData.Parent); errRes != nil {
+			return nil, errRes
+		}
+	}
+	// Upload to storage, the space is charged to the owner
+	ownerType := ""
+	if fileData.User == idObjUser {
+		ownerType = claims.UserType
+	}
+	stored, errRes := f.uploadToStorage(
+		file,
+		originalFilename,
+		claims,
+		fileData.User,
+		ownerType,
+		limits,
+	)
+	if errRes != nil {
+		return nil, errRes
+	}
+	current := models.FileVersion{
+		Version:    fileData.LastVersion() + 1,
+		Filename:   filename,
+		Key:        stored.Key,
+		URL:        stored.Location,
+		Type:       stored.Type,
+		Size:       stored.Size,
+		Checksum:   stored.Checksum,
+		ScanStatus: f.initialScanStatus(),
+		User:       idObjUser,
+		Date:       primitive.NewDateTimeFromTime(time.Now()),
+	}
+	versions := append(fileData.Versions, f.currentAsVersion(fileData))
+	if errRes := f.setCurrentVersion(fileData, current, versions); errRes != nil {
+		f.discardStored(stored, fileData.User)
+		return nil, errRes
+	}
+	f.processContent(current.Key, current.Type)
+	return f.getFile(idFile)
+}
+
+// Versions of the file, from the newest to the oldest
+func (f *FilesService) GetVersions(idFile, idUser string) ([]models.FileVersion, *ErrorRes) {
+	file, errRes := f.getUserFile(idFile, idUser)
+	if errRes != nil {
+		return nil, errRes
+	}
+	versions := append([]models.FileVersion{f.currentAsVersion(file)}, file.Versions...)
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version > versions[j].Version
+	})
+	return versions, nil
+}
+
+func (f *FilesService) GetVersion(idFile, idUser string, version int) (string, *ErrorRes) {
+	file, errRes := f.getUserFile(idFile, idUser)
+	if errRes != nil {
+		return "", errRes
+	}
+	key := file.Key
+	scanStatus := file.ScanStatus
+	if version != file.CurrentVersion() {
+		fileVersion := file.GetVersion(version)
+		if fileVersion == nil {
+			return "", &ErrorRes{
+				Err:        errors.New("la versión no existe"),
+				StatusCode: http.StatusNotFound,
+			}
+		}
+		key = fileVersion.Key
+		scanStatus = fileVersion.ScanStatus
+	}
+	if errRes := f.checkScanStatus(scanStatus); errRes != nil {
+		return "", errRes
+	}
+	urlStr, err := fileStorage.GetFileToken(key)
+	if err != nil {
+		return "", &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return urlStr, nil
+}
+
+// The restored version becomes the current one and the current
+// one goes to the history, so no content is lost
+func (f *FilesService) RestoreVersion(idFile, idUser string, version int) (*models.File, *ErrorRes) {
+	file, errRes := f.getUserFile(idFile, idUser)
+	if errRes != nil {
+		return nil, errRes
+	}
+	if version == file.CurrentVersion() {
+		return nil, &ErrorRes{
+			Err:        errors.New("la versión ya es la actual"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	restored := file.GetVersion(version)
+	if restored == nil {
+		return nil, &ErrorRes{
+			Err:        errors.New("la versión no existe"),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	if restored.Filename != "" && restored.Filename != file.Filename {
+		if errRes := f.checkFilename(restored.Filename, file.User, file.Parent); errRes != nil {
+			return nil, errRes
+		}
+	} else if restored.Filename == "" {
+		restored.Filename = file.Filename
+	}
+	versions := []models.FileVersion{f.currentAsVersion(file)}
+	for _, fileVersion := range file.Versions {
+		if fileVersion.Version != version {
+			versions = append(versions, fileVersion)
+		}
+	}
+	if errRes := f.setCurrentVersion(file, *restored, versions); errRes != nil {
+		return nil, errRes
+	}
+	// A content not scanned yet gets them after the scan
+	if f.checkScanStatus(restored.ScanStatus) == nil {
+		f.queueDerived(restored.Key, restored.Type)
+	}
+	return f.getFile(idFile)
+}
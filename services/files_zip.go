@@ -0,0 +1,248 @@
+package services
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const ZIP_MAX_FILES = 500
+
+// Entry listing the files left out of the zip
+const ZIP_SKIPPED_NAME = "archivos_omitidos.txt"
+
+// ZipEntry is a file of the zip and its path inside it
+type ZipEntry struct {
+	Name string
+	File *models.File
+}
+
+// Path of every folder of the user, relative to root or to the
+// folder given
+func (f *FilesService) getFolderPaths(idUser primitive.ObjectID, root primitive.ObjectID) (map[primitive.ObjectID]string, *ErrorRes) {
+	var folders []models.Folder
+	cursor, err := foldersModel.Use().Find(db.Ctx, bson.M{
+		"user": idUser,
+	})
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if err := cursor.All(db.Ctx, &folders); err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	byID := make(map[primitive.ObjectID]*models.Folder)
+	for i := range folders {
+		byID[folders[i].ID] = &folders[i]
+	}
+
+	paths := make(map[primitive.ObjectID]string)
+	for _, folder := range folders {
+		names := []string{}
+		inRoot := root.IsZero()
+		for current := &folder; current != nil && len(names) <= len(folders); {
+			if current.ID == root {
+				inRoot = true
+				break
+			}
+			names = append([]string{utils.ZipSegment(current.Name)}, names...)
+			current = byID[current.Parent]
+		}
+		if inRoot {
+			paths[folder.ID] = path.Join(names...)
+		}
+	}
+	if !root.IsZero() {
+		paths[root] = ""
+	}
+	return paths, nil
+}
+
+// Files of the filter, the ones not clean are skipped and returned
+// with the reason
+func (f *FilesService) getFilteredZipFiles(zipData forms.ZipForm, claims *Claims) ([]ZipEntry, []string, *ErrorRes) {
+	idObjUser, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		return nil, nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	root := primitive.NilObjectID
+	if zipData.Folder != "" {
+		folder, errRes := foldersService.getFolder(zipData.Folder, claims.ID)
+		if errRes != nil {
+			return nil, nil, errRes
+		}
+		root = folder.ID
+	}
+	paths, errRes := f.getFolderPaths(idObjUser, root)
+	if errRes != nil {
+		return nil, nil, errRes
+	}
+
+	filter := bson.M{
+		"user":   idObjUser,
+		"status": true,
+	}
+	if zipData.Permissions != "" {
+		filter["permissions"] = zipData.Permissions
+	}
+	// Only the folder and its subfolders
+	if !root.IsZero() {
+		folders := bson.A{}
+		for idFolder := range paths {
+			folders = append(folders, idFolder)
+		}
+		filter["parent"] = bson.M{"$in": folders}
+	}
+	var files []models.File
+	opts := options.Find().SetLimit(ZIP_MAX_FILES + 1)
+	cursor, err := filesModel.Use().Find(db.Ctx, filter, opts)
+	if err != nil {
+		return nil, nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if err := cursor.All(db.Ctx, &files); err != nil {
+		return nil, nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if len(files) > ZIP_MAX_FILES {
+		return nil, nil, &ErrorRes{
+			Err:        fmt.Errorf("el zip no puede tener más de %d archivos", ZIP_MAX_FILES),
+			StatusCode: http.StatusRequestEntityTooLarge,
+		}
+	}
+	var entries []ZipEntry
+	var skipped []string
+	for i := range files {
+		name := utils.ZipPath(paths[files[i].Parent], files[i].Filename)
+		if errRes := f.checkScanStatus(files[i].ScanStatus); errRes != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", name, errRes.Err))
+			continue
+		}
+		entries = append(entries, ZipEntry{
+			Name: name,
+			File: &files[i],
+		})
+	}
+	return entries, skipped, nil
+}
+
+// Files of the zip, every one is checked before writing anything.
+// The files of a filter not scanned yet or infected are skipped
+func (f *FilesService) GetZipEntries(zipData forms.ZipForm, claims *Claims) ([]ZipEntry, []string, *ErrorRes) {
+	var entries []ZipEntry
+	var skipped []string
+	if len(zipData.Files) > 0 {
+		for _, idFile := range zipData.Files {
+			file, errRes := f.getReadableFile(idFile, claims)
+			if errRes != nil {
+				return nil, nil, errRes
+			}
+			entries = append(entries, ZipEntry{
+				Name: utils.ZipSegment(file.Filename),
+				File: file,
+			})
+		}
+	} else {
+		var errRes *ErrorRes
+		entries, skipped, errRes = f.getFilteredZipFiles(zipData, claims)
+		if errRes != nil {
+			return nil, nil, errRes
+		}
+	}
+	if len(entries) == 0 {
+		return nil, nil, &ErrorRes{
+			Err:        errors.New("no hay archivos para descargar"),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	names := make([]string, len(entries))
+	for i := range entries {
+		names[i] = entries[i].Name
+	}
+	var reserved []string
+	if len(skipped) > 0 {
+		reserved = append(reserved, ZIP_SKIPPED_NAME)
+	}
+	for i, name := range utils.UniqueZipNames(names, reserved...) {
+		entries[i].Name = name
+	}
+	return entries, skipped, nil
+}
+
+// Already compressed contents are stored as they are
+func isCompressed(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "video/"), strings.HasPrefix(mimeType, "audio/"):
+		return true
+	case mimeType == "image/jpeg", mimeType == "image/png", mimeType == "image/gif", mimeType == "image/webp":
+		return true
+	case mimeType == "application/zip", strings.Contains(mimeType, "openxmlformats"):
+		return true
+	default:
+		return false
+	}
+}
+
+// Write the zip reading every file from the storage, only one
+// file is read at a time. The skipped files are listed in a text
+// file of the zip
+func (f *FilesService) WriteZip(w io.Writer, entries []ZipEntry, skipped []string) error {
+	zipWriter := zip.NewWriter(w)
+	for _, entry := range entries {
+		header := &zip.FileHeader{
+			Name:     entry.Name,
+			Method:   zip.Deflate,
+			Modified: entry.File.Date.Time(),
+		}
+		if isCompressed(entry.File.Type) {
+			header.Method = zip.Store
+		}
+		dst, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		content, err := fileStorage.GetFile(entry.File.Key)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if len(skipped) > 0 {
+		dst, err := zipWriter.Create(ZIP_SKIPPED_NAME)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(dst, strings.Join(skipped, "\n")+"\n"); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var foldersModel = new(models.FoldersModel)
+
+var foldersService *FoldersService
+
+type FoldersService struct{}
+
+type FolderContent struct {
+	Folder      *models.Folder
+	Breadcrumbs []models.Folder
+	Folders     []models.Folder
+	Files       []models.File
+}
+
+func (f *FoldersService) getFolder(idFolder, idUser string) (*models.Folder, *ErrorRes) {
+	idObjFolder, err := primitive.ObjectIDFromHex(idFolder)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	var folder *models.Folder
+	cursor := foldersModel.Use().FindOne(db.Ctx, bson.D{{
+		Key:   "_id",
+		Value: idObjFolder,
+	}})
+	if err := cursor.Decode(&folder); err != nil {
+		if err.Error() == db.NO_SINGLE_DOCUMENT {
+			return nil, &ErrorRes{
+				Err:        errors.New("la carpeta no existe"),
+				StatusCode: http.StatusNotFound,
+			}
+		}
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if folder.User.Hex() != idUser {
+		return nil, &ErrorRes{
+			Err:        errors.New("la carpeta le pertenece a otro usuario"),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	return folder, nil
+}
+
+// An empty parent is the root
+func (f *FoldersService) getParent(idParent, idUser string) (primitive.ObjectID, *ErrorRes) {
+	if idParent == "" {
+		return primitive.NilObjectID, nil
+	}
+	folder, errRes := f.getFolder(idParent, idUser)
+	if errRes != nil {
+		return primitive.NilObjectID, errRes
+	}
+	return folder.ID, nil
+}
+
+func (f *FoldersService) getMatchParent(parent primitive.ObjectID) bson.M {
+	if parent.IsZero() {
+		return bson.M{
+			"$exists": false,
+		}
+	}
+	return bson.M{
+		"$eq": parent,
+	}
+}
+
+func (f *FoldersService) checkName(name string, idUser primitive.ObjectID, parent primitive.ObjectID) *ErrorRes {
+	count, err := foldersModel.Use().CountDocuments(db.Ctx, bson.M{
+		"user":   idUser,
+		"name":   name,
+		"parent": f.getMatchParent(parent),
+	})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if count > 0 {
+		return &ErrorRes{
+			Err:        errors.New("ya existe una carpeta con este nombre"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return nil
+}
+
+// Ancestors of the folder, from the root to the folder itself
+func (f *FoldersService) getBreadcrumbs(folder *models.Folder) ([]models.Folder, *ErrorRes) {
+	pipeline := mongo.Pipeline{
+		bson.D{{
+			Key: "$match",
+			Value: bson.M{
+				"_id": folder.ID,
+			},
+		}},
+		bson.D{{
+			Key: "$graphLookup",
+			Value: bson.M{
+				"from":             models.FOLDERS_COLLECTION,
+				"startWith":        "$parent",
+				"connectFromField": "parent",
+				"connectToField":   "_id",
+				"as":               "ancestors",
+				"depthField":       "depth",
+			},
+		}},
+		bson.D{{
+			Key: "$unwind",
+			Value: bson.M{
+				"path": "$ancestors",
+			},
+		}},
+		bson.D{{
+			Key: "$sort",
+			Value: bson.M{
+				"ancestors.depth": -1,
+			},
+		}},
+		bson.D{{
+			Key: "$replaceRoot",
+			Value: bson.M{
+				"newRoot": "$ancestors",
+			},
+		}},
+	}
+	cursor, err := foldersModel.Use().Aggregate(db.Ctx, pipeline)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	var breadcrumbs []models.Folder
+	if err := cursor.All(db.Ctx, &breadcrumbs); err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return append(breadcrumbs, *folder), nil
+}
+
+func (f *FoldersService) CreateFolder(folderData forms.FolderForm, idUser string) (*models.Folder, *ErrorRes) {
+	parent, errRes := f.getParent(folderData.Parent, idUser)
+	if errRes != nil {
+		return nil, errRes
+	}
+	folder, err := foldersModel.NewModel(folderData.Name, idUser, parent)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if errRes := f.checkName(folder.Name, folder.User, parent); errRes != nil {
+		return nil, errRes
+	}
+	inserted, err := foldersModel.Use().InsertOne(db.Ctx, folder)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	folder.ID = inserted.InsertedID.(primitive.ObjectID)
+	return folder, nil
+}
+
+// Content of a folder, an empty idFolder is the root
+func (f *FoldersService) GetFolder(idFolder, idUser string) (*FolderContent, *ErrorRes) {
+	idObjUser, err := primitive.ObjectIDFromHex(idUser)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	content := &FolderContent{}
+	parent := primitive.NilObjectID
+	if idFolder != "" {
+		folder, errRes := f.getFolder(idFolder, idUser)
+		if errRes != nil {
+			return nil, errRes
+		}
+		breadcrumbs, errRes := f.getBreadcrumbs(folder)
+		if errRes != nil {
+			return nil, errRes
+		}
+		content.Folder = folder
+		content.Breadcrumbs = breadcrumbs
+		parent = folder.ID
+	}
+	// Folders
+	cursor, err := foldersModel.Use().Find(db.Ctx, bson.M{
+		"user":   idObjUser,
+		"parent": f.getMatchParent(parent),
+	})
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if err := cursor.All(db.Ctx, &content.Folders); err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	// Files
+	cursor, err = filesModel.Use().Find(db.Ctx, bson.M{
+		"user":   idObjUser,
+		"status": true,
+		"parent": f.getMatchParent(parent),
+	})
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if err := cursor.All(db.Ctx, &content.Files); err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return content, nil
+}
+
+func (f *FoldersService) RenameFolder(idFolder, idUser, name string) *ErrorRes {
+	folder, errRes := f.getFolder(idFolder, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	if folder.Name == name {
+		return nil
+	}
+	if errRes := f.checkName(name, folder.User, folder.Parent); errRes != nil {
+		return errRes
+	}
+	_, err := foldersModel.Use().UpdateByID(db.Ctx, folder.ID, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"name": name,
+		},
+	}})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return nil
+}
+
+func (f *FoldersService) getUpdateParent(parent primitive.ObjectID) bson.D {
+	if parent.IsZero() {
+		return bson.D{{
+			Key: "$unset",
+			Value: bson.M{
+				"parent": "",
+			},
+		}}
+	}
+	return bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"parent": parent,
+		},
+	}}
+}
+
+func (f *FoldersService) MoveFolder(idFolder, idUser, idParent string) *ErrorRes {
+	folder, errRes := f.getFolder(idFolder, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	parent, errRes := f.getParent(idParent, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	// The folder can't be moved inside itself
+	if !parent.IsZero() {
+		parentFolder, errRes := f.getFolder(idParent, idUser)
+		if errRes != nil {
+			return errRes
+		}
+		breadcrumbs, errRes := f.getBreadcrumbs(parentFolder)
+		if errRes != nil {
+			return errRes
+		}
+		for _, ancestor := range breadcrumbs {
+			if ancestor.ID == folder.ID {
+				return &ErrorRes{
+					Err:        errors.New("no se puede mover una carpeta dentro de sí misma"),
+					StatusCode: http.StatusBadRequest,
+				}
+			}
+		}
+	}
+	if errRes := f.checkName(folder.Name, folder.User, parent); errRes != nil {
+		return errRes
+	}
+	_, err := foldersModel.Use().UpdateByID(db.Ctx, folder.ID, f.getUpdateParent(parent))
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return nil
+}
+
+// Only empty folders can be deleted
+func (f *FoldersService) DeleteFolder(idFolder, idUser string) *ErrorRes {
+	folder, errRes := f.getFolder(idFolder, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	folders, err := foldersModel.Use().CountDocuments(db.Ctx, bson.M{
+		"parent": folder.ID,
+	})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	files, err := filesModel.Use().CountDocuments(db.Ctx, bson.M{
+		"parent": folder.ID,
+		"status": true,
+	})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if folders > 0 || files > 0 {
+		return &ErrorRes{
+			Err:        errors.New("la carpeta no está vacía"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	_, err = foldersModel.Use().DeleteOne(db.Ctx, bson.M{
+		"_id": folder.ID,
+	})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return nil
+}
+
+func (f *FoldersService) MoveFile(idFile, idUser, idParent string) *ErrorRes {
+	file, errRes := filesService.getUserFile(idFile, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	parent, errRes := f.getParent(idParent, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	if parent == file.Parent {
+		return nil
+	}
+	if errRes := filesService.checkFilename(file.Filename, file.User, parent); errRes != nil {
+		return errRes
+	}
+	_, err := filesModel.Use().UpdateByID(db.Ctx, file.ID, f.getUpdateParent(parent))
+	if err != nil {
+		return filesService.filenameError(err)
+	}
+	return nil
+}
+
+func NewFoldersService() *FoldersService {
+	if foldersService == nil {
+		foldersService = &FoldersService{}
+	}
+	return foldersService
+}
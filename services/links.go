@@ -0,0 +1,345 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const LINK_TOKEN_SIZE = 32
+
+// Wrong passwords allowed before locking the link, and the time
+// it stays locked
+const (
+	LINK_MAX_ATTEMPTS  = 5
+	LINK_LOCK_DURATION = 15 * time.Minute
+)
+
+// Bytes of the password hashed by bcrypt, the form counts characters
+// and an accented character takes more than one byte
+const LINK_PASSWORD_MAX_BYTES = 72
+
+var linksModel = new(models.LinksModel)
+
+var linksService *LinksService
+
+type LinksService struct{}
+
+func (l *LinksService) newToken() (string, error) {
+	token := make([]byte, LINK_TOKEN_SIZE)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func (l *LinksService) CreateLink(idFile, idUser string, linkData forms.LinkForm) (*models.Link, *ErrorRes) {
+	file, errRes := filesService.getUserFile(idFile, idUser)
+	if errRes != nil {
+		return nil, errRes
+	}
+	var expiresAt time.Time
+	if linkData.ExpiresAt != 0 {
+		expiresAt = time.Unix(linkData.ExpiresAt, 0)
+		if expiresAt.Before(time.Now()) {
+			return nil, &ErrorRes{
+				Err:        errors.New("la fecha de expiración debe ser futura"),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+	if len(linkData.Password) > LINK_PASSWORD_MAX_BYTES {
+		return nil, &ErrorRes{
+			Err:        errors.New("la contraseña es demasiado larga"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	var password string
+	if linkData.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(linkData.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, &ErrorRes{
+				Err:        err,
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+		password = string(hash)
+	}
+	token, err := l.newToken()
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	link := linksModel.NewModel(
+		file.ID,
+		file.User,
+		token,
+		password,
+		expiresAt,
+		linkData.MaxDownloads,
+	)
+	inserted, err := linksModel.Use().InsertOne(db.Ctx, link)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	link.ID = inserted.InsertedID.(primitive.ObjectID)
+	return link, nil
+}
+
+// Links not revoked nor expired of the file
+func (l *LinksService) GetLinks(idFile, idUser string) ([]models.Link, *ErrorRes) {
+	file, errRes := filesService.getUserFile(idFile, idUser)
+	if errRes != nil {
+		return nil, errRes
+	}
+	var links []models.Link
+
+	opts := options.Find().SetSort(bson.D{{
+		Key:   "date",
+		Value: -1,
+	}})
+	cursor, err := linksModel.Use().Find(db.Ctx, bson.M{
+		"file":   file.ID,
+		"status": true,
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": primitive.NewDateTimeFromTime(time.Now())}},
+		},
+	}, opts)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if err := cursor.All(db.Ctx, &links); err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return links, nil
+}
+
+func (l *LinksService) RevokeLink(idLink, idUser string) *ErrorRes {
+	idObjLink, err := primitive.ObjectIDFromHex(idLink)
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	idObjUser, err := primitive.ObjectIDFromHex(idUser)
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	result, err := linksModel.Use().UpdateOne(db.Ctx, bson.M{
+		"_id":  idObjLink,
+		"user": idObjUser,
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"status": false,
+		},
+	}})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if result.MatchedCount == 0 {
+		return &ErrorRes{
+			Err:        errors.New("el enlace no existe"),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return nil
+}
+
+func (l *LinksService) getLink(token string) (*models.Link, *ErrorRes) {
+	var link *models.Link
+	cursor := linksModel.Use().FindOne(db.Ctx, bson.M{
+		"token": token,
+	})
+	if err := cursor.Decode(&link); err != nil {
+		if err.Error() == db.NO_SINGLE_DOCUMENT {
+			return nil, &ErrorRes{
+				Err:        errors.New("el enlace no existe"),
+				StatusCode: http.StatusNotFound,
+			}
+		}
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return link, nil
+}
+
+func (l *LinksService) lockedError() *ErrorRes {
+	return &ErrorRes{
+		Err:        errors.New("el enlace está bloqueado por demasiados intentos, intente más tarde"),
+		StatusCode: http.StatusTooManyRequests,
+	}
+}
+
+// Check the password of the link. Every attempt is counted before
+// comparing it, so concurrent requests can not go over the limit
+func (l *LinksService) checkPassword(link *models.Link, password string) *ErrorRes {
+	now := time.Now()
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var counted *models.Link
+	err := linksModel.Use().FindOneAndUpdate(db.Ctx, bson.M{
+		"_id": link.ID,
+		"$or": bson.A{
+			bson.M{"locked_until": bson.M{"$exists": false}},
+			bson.M{"locked_until": bson.M{"$lte": primitive.NewDateTimeFromTime(now)}},
+		},
+	}, bson.D{{
+		Key: "$inc",
+		Value: bson.M{
+			"failed_attempts": 1,
+		},
+	}}, opts).Decode(&counted)
+	if err == mongo.ErrNoDocuments {
+		return l.lockedError()
+	}
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if counted.FailedAttempts > LINK_MAX_ATTEMPTS {
+		return l.lockedError()
+	}
+
+	update := bson.M{
+		"$unset": bson.M{
+			"failed_attempts": "",
+			"locked_until":    "",
+		},
+	}
+	var errRes *ErrorRes
+	if bcrypt.CompareHashAndPassword([]byte(link.Password), []byte(password)) != nil {
+		errRes = &ErrorRes{
+			Err:        errors.New("la contraseña es incorrecta"),
+			StatusCode: http.StatusUnauthorized,
+		}
+		if counted.FailedAttempts < LINK_MAX_ATTEMPTS {
+			return errRes
+		}
+		update = bson.M{
+			"$set": bson.M{
+				"locked_until": primitive.NewDateTimeFromTime(now.Add(LINK_LOCK_DURATION)),
+			},
+			"$unset": bson.M{
+				"failed_attempts": "",
+			},
+		}
+	}
+	_, err = linksModel.Use().UpdateOne(db.Ctx, bson.M{
+		"_id": link.ID,
+	}, update)
+	if err != nil && errRes == nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return errRes
+}
+
+// Resolve a link to a token of the file, counting the download
+func (l *LinksService) AccessLink(token, password string) (string, *models.File, *ErrorRes) {
+	link, errRes := l.getLink(token)
+	if errRes != nil {
+		return "", nil, errRes
+	}
+	if !link.Status || link.IsExpired() {
+		return "", nil, &ErrorRes{
+			Err:        errors.New("el enlace fue revocado o ha expirado"),
+			StatusCode: http.StatusGone,
+		}
+	}
+	if link.Password != "" {
+		if errRes := l.checkPassword(link, password); errRes != nil {
+			return "", nil, errRes
+		}
+	}
+	file, errRes := filesService.getFile(link.File.Hex())
+	if errRes != nil {
+		return "", nil, errRes
+	}
+	if !file.Status {
+		return "", nil, &ErrorRes{
+			Err:        errors.New("el archivo está eliminado"),
+			StatusCode: http.StatusGone,
+		}
+	}
+	if errRes := filesService.checkScanStatus(file.ScanStatus); errRes != nil {
+		return "", nil, errRes
+	}
+	// The filter makes the count atomic with the limit
+	result, err := linksModel.Use().UpdateOne(db.Ctx, bson.M{
+		"_id":    link.ID,
+		"status": true,
+		"$or": bson.A{
+			bson.M{"max_downloads": 0},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$downloads", "$max_downloads"}}},
+		},
+	}, bson.D{{
+		Key: "$inc",
+		Value: bson.M{
+			"downloads": 1,
+		},
+	}})
+	if err != nil {
+		return "", nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if result.MatchedCount == 0 {
+		return "", nil, &ErrorRes{
+			Err:        errors.New("el enlace alcanzó el máximo de descargas"),
+			StatusCode: http.StatusGone,
+		}
+	}
+	urlStr, err := fileStorage.GetFileToken(file.Key)
+	if err != nil {
+		return "", nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return urlStr, file, nil
+}
+
+func NewLinksService() *LinksService {
+	if linksService == nil {
+		linksService = &LinksService{}
+	}
+	return linksService
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/previews"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Rendering documents is heavy, they are rendered one by one
+const PREVIEWS_CONCURRENCY = 1
+
+var previewsQueue = make(chan struct{}, PREVIEWS_CONCURRENCY)
+
+// Preview is a page of the preview with the token to read it
+type Preview struct {
+	Page  int
+	Pages int
+	Type  string
+	Token string
+}
+
+// Previews are stored next to the original, "<key>_preview_<page>.<ext>"
+func previewKey(key string, page int, ext string) string {
+	return fmt.Sprintf("%s_preview_%d%s", strings.TrimSuffix(key, filepath.Ext(key)), page, ext)
+}
+
+func hasPreview(key string) bool {
+	ext := filepath.Ext(key)
+	return previews.IsDocument(ext) || previews.IsText(ext)
+}
+
+func (f *FilesService) renderPreviews(key string) ([]models.FilePreview, error) {
+	content, err := fileStorage.GetFile(key)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	ext := filepath.Ext(key)
+	var pages [][]byte
+	pageExt := ".png"
+	if previews.IsText(ext) {
+		text, err := previews.RenderText(content)
+		if err != nil {
+			return nil, err
+		}
+		pages = [][]byte{text}
+		pageExt = ".txt"
+	} else {
+		pages, err = previews.RenderDocument(content, ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filePreviews := []models.FilePreview{}
+	for i, page := range pages {
+		keyPage := previewKey(key, i+1, pageExt)
+		location, err := fileStorage.PutFile(keyPage, bytes.NewReader(page))
+		if err != nil {
+			return nil, err
+		}
+		typePreview := "image/png"
+		if pageExt == ".txt" {
+			typePreview = "text/plain"
+		}
+		filePreviews = append(filePreviews, models.FilePreview{
+			Page: i + 1,
+			Key:  keyPage,
+			URL:  location,
+			Type: typePreview,
+			Size: int64(len(page)),
+		})
+	}
+	return filePreviews, nil
+}
+
+func (f *FilesService) setPreviews(key, status string, filePreviews []models.FilePreview) error {
+	update := bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"preview_status": status,
+			"previews":       filePreviews,
+		},
+	}}
+	if filePreviews == nil {
+		update = bson.D{
+			{Key: "$set", Value: bson.M{"preview_status": status}},
+			{Key: "$unset", Value: bson.M{"previews": ""}},
+		}
+	}
+	_, err := filesModel.Use().UpdateMany(db.Ctx, bson.M{
+		"key": key,
+	}, update)
+	return err
+}
+
+// Render the previews in background, so uploads are not delayed.
+// Contents already rendered for another file are reused
+func (f *FilesService) queuePreview(key string) {
+	if !hasPreview(key) {
+		return
+	}
+	_, err := filesModel.Use().UpdateMany(db.Ctx, bson.M{
+		"key":            key,
+		"preview_status": bson.M{"$exists": false},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"preview_status": models.PREVIEW_PENDING,
+		},
+	}})
+	if err != nil {
+		fmt.Printf("Error queueing preview of %v: %v\n", key, err)
+		return
+	}
+	go func() {
+		previewsQueue <- struct{}{}
+		defer func() { <-previewsQueue }()
+
+		var file *models.File
+		err := filesModel.Use().FindOne(db.Ctx, bson.M{
+			"key":            key,
+			"preview_status": models.PREVIEW_READY,
+		}).Decode(&file)
+		if err == nil {
+			err = f.setPreviews(key, models.PREVIEW_READY, file.Previews)
+		} else {
+			var filePreviews []models.FilePreview
+			filePreviews, err = f.renderPreviews(key)
+			if err != nil {
+				f.setPreviews(key, models.PREVIEW_ERROR, nil)
+			} else {
+				err = f.setPreviews(key, models.PREVIEW_READY, filePreviews)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Error rendering preview of %v: %v\n", key, err)
+		}
+	}()
+}
+
+func (f *FilesService) GetPreview(idFile string, page int, claims *Claims) (*Preview, *ErrorRes) {
+	file, errRes := f.getReadableFile(idFile, claims)
+	if errRes != nil {
+		return nil, errRes
+	}
+	switch file.PreviewStatus {
+	case models.PREVIEW_PENDING:
+		return nil, &ErrorRes{
+			Err:        errors.New("la vista previa aún se está generando"),
+			StatusCode: http.StatusConflict,
+		}
+	case models.PREVIEW_ERROR:
+		return nil, &ErrorRes{
+			Err:        errors.New("no se pudo generar la vista previa del archivo"),
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	case models.PREVIEW_READY:
+	default:
+		return nil, &ErrorRes{
+			Err:        previews.ErrNoPreview,
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	filePreview := file.GetPreview(page)
+	if filePreview == nil {
+		return nil, &ErrorRes{
+			Err:        errors.New("la página no tiene vista previa"),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	urlStr, err := fileStorage.GetFileToken(filePreview.Key)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return &Preview{
+		Page:  filePreview.Page,
+		Pages: len(file.Previews),
+		Type:  filePreview.Type,
+		Token: urlStr,
+	}, nil
+}
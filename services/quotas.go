@@ -0,0 +1,272 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/settings"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const UNLIMITED_QUOTA = -1
+
+var quotasModel = new(models.QuotasModel)
+
+var quotasService *QuotasService
+
+type QuotasService struct{}
+
+type QuotaUsage struct {
+	Used      int64
+	Quota     int64
+	Remaining int64
+}
+
+func (q *QuotasService) getTypeQuota(userType string) int64 {
+	settingsData := settings.GetSettings()
+	switch userType {
+	case models.DIRECTOR:
+		return settingsData.QUOTA_DIRECTOR
+	case models.DIRECTIVE:
+		return settingsData.QUOTA_DIRECTIVE
+	case models.TEACHER:
+		return settingsData.QUOTA_TEACHER
+	// Students and attorneys only upload the work of the classes,
+	// their space is unlimited by design
+	case models.STUDENT, models.STUDENT_DIRECTIVE, models.ATTORNEY:
+		return UNLIMITED_QUOTA
+	// The type is unset until the owner makes a request, the used
+	// space is still counted and limited once the type is known
+	default:
+		return UNLIMITED_QUOTA
+	}
+}
+
+func (q *QuotasService) isUserType(userType string) bool {
+	for _, known := range userTypes {
+		if known == userType {
+			return true
+		}
+	}
+	return false
+}
+
+// Space taken by the files the user already has
+func (q *QuotasService) getFilesUsage(idUser primitive.ObjectID) (int64, error) {
+	cursor, err := filesModel.Use().Aggregate(db.Ctx, models.UsagePipeline(bson.M{
+		"user": idUser,
+	}))
+	if err != nil {
+		return 0, err
+	}
+	var usage []struct {
+		Used int64 `bson:"used"`
+	}
+	if err := cursor.All(db.Ctx, &usage); err != nil {
+		return 0, err
+	}
+	if len(usage) == 0 {
+		return 0, nil
+	}
+	return usage[0].Used, nil
+}
+
+// Get the quota document of the user, creating it if not exists.
+// A new document starts with the files of the user. The type is
+// only stored when it is known, requests made by other users pass
+// an empty userType and keep the one stored, or leave it unset
+// until the owner makes a request
+func (q *QuotasService) getQuota(idUser primitive.ObjectID, userType string) (*models.Quota, *ErrorRes) {
+	count, err := quotasModel.Use().CountDocuments(db.Ctx, bson.M{
+		"user": idUser,
+	})
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	used := int64(0)
+	if count == 0 {
+		used, err = q.getFilesUsage(idUser)
+		if err != nil {
+			return nil, &ErrorRes{
+				Err:        err,
+				StatusCode: http.StatusServiceUnavailable,
+			}
+		}
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"used": used,
+		},
+	}
+	if q.isUserType(userType) {
+		update["$set"] = bson.M{
+			"user_type": userType,
+		}
+	}
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var quota *models.Quota
+	err = quotasModel.Use().FindOneAndUpdate(db.Ctx, bson.M{
+		"user": idUser,
+	}, update, opts).Decode(&quota)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return quota, nil
+}
+
+func (q *QuotasService) getUsage(quota *models.Quota) *QuotaUsage {
+	limit := q.getTypeQuota(quota.UserType)
+	if quota.Quota != nil {
+		limit = *quota.Quota
+	}
+	usage := &QuotaUsage{
+		Used:      quota.Used,
+		Quota:     limit,
+		Remaining: UNLIMITED_QUOTA,
+	}
+	if limit != UNLIMITED_QUOTA {
+		usage.Remaining = limit - quota.Used
+		if usage.Remaining < 0 {
+			usage.Remaining = 0
+		}
+	}
+	return usage
+}
+
+func (q *QuotasService) getUserUsage(idUser primitive.ObjectID, userType string) (*QuotaUsage, *ErrorRes) {
+	quota, errRes := q.getQuota(idUser, userType)
+	if errRes != nil {
+		return nil, errRes
+	}
+	return q.getUsage(quota), nil
+}
+
+func (q *QuotasService) quotaError(usage *QuotaUsage) *ErrorRes {
+	return &ErrorRes{
+		Err: fmt.Errorf(
+			"el archivo excede su cuota de almacenamiento, le quedan %v bytes disponibles",
+			usage.Remaining,
+		),
+		StatusCode: http.StatusRequestEntityTooLarge,
+	}
+}
+
+// Add the size to the used space, the filter makes it fail
+// when another upload took the space meanwhile
+func (q *QuotasService) consume(idUser primitive.ObjectID, size int64, usage *QuotaUsage) *ErrorRes {
+	filter := bson.M{
+		"user": idUser,
+	}
+	if usage.Quota != UNLIMITED_QUOTA {
+		filter["used"] = bson.M{
+			"$lte": usage.Quota - size,
+		}
+	}
+	result, err := quotasModel.Use().UpdateOne(db.Ctx, filter, bson.D{{
+		Key: "$inc",
+		Value: bson.M{
+			"used": size,
+		},
+	}})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if result.MatchedCount == 0 {
+		return q.quotaError(usage)
+	}
+	return nil
+}
+
+func (q *QuotasService) release(idUser primitive.ObjectID, size int64) error {
+	if size == 0 || idUser.IsZero() {
+		return nil
+	}
+	// The used space never goes below zero
+	_, err := quotasModel.Use().UpdateOne(db.Ctx, bson.M{
+		"user": idUser,
+	}, mongo.Pipeline{bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"used": bson.M{
+				"$max": bson.A{
+					int64(0),
+					bson.M{"$subtract": bson.A{"$used", size}},
+				},
+			},
+		},
+	}}})
+	return err
+}
+
+func (q *QuotasService) GetUsage(claims *Claims) (*QuotaUsage, *ErrorRes) {
+	idObjUser, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return q.getUserUsage(idObjUser, claims.UserType)
+}
+
+// Set the quota of an user, nil restores the quota of its type
+func (q *QuotasService) SetUserQuota(idUser string, quota *int64) (*QuotaUsage, *ErrorRes) {
+	idObjUser, err := primitive.ObjectIDFromHex(idUser)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if _, errRes := q.getQuota(idObjUser, ""); errRes != nil {
+		return nil, errRes
+	}
+	update := bson.D{{
+		Key: "$unset",
+		Value: bson.M{
+			"quota": "",
+		},
+	}}
+	if quota != nil {
+		update = bson.D{{
+			Key: "$set",
+			Value: bson.M{
+				"quota": *quota,
+			},
+		}}
+	}
+	_, err = quotasModel.Use().UpdateOne(db.Ctx, bson.M{
+		"user": idObjUser,
+	}, update)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return q.getUserUsage(idObjUser, "")
+}
+
+func NewQuotasService() *QuotasService {
+	if quotasService == nil {
+		quotasService = &QuotasService{}
+	}
+	return quotasService
+}
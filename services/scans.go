@@ -0,0 +1,318 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const SCAN_CONCURRENCY = 2
+
+const SCAN_RETRY_INTERVAL = 10 * time.Minute
+
+// Infected contents are moved under this prefix, out of the keys
+// served to the users
+const QUARANTINE_PREFIX = "quarantine/"
+
+var scanQueue = make(chan struct{}, SCAN_CONCURRENCY)
+
+// Status of new contents, empty when scanning is disabled
+func (f *FilesService) initialScanStatus() string {
+	if fileScanner == nil {
+		return ""
+	}
+	return models.SCAN_PENDING
+}
+
+// Contents without status were stored while scanning was disabled,
+// they are pending until the worker scans them. With scanning
+// disabled nothing would clear them, so only infected contents
+// are blocked
+func (f *FilesService) checkScanStatus(scanStatus string) *ErrorRes {
+	if fileScanner == nil && scanStatus != models.SCAN_INFECTED {
+		return nil
+	}
+	switch scanStatus {
+	case models.SCAN_CLEAN:
+		return nil
+	case "", models.SCAN_PENDING:
+		return &ErrorRes{
+			Err:        errors.New("el archivo aún está siendo analizado"),
+			StatusCode: http.StatusConflict,
+		}
+	case models.SCAN_INFECTED:
+		return &ErrorRes{
+			Err:        errors.New("el archivo está en cuarentena por contener un virus"),
+			StatusCode: http.StatusForbidden,
+		}
+	default:
+		return &ErrorRes{
+			Err:        errors.New("no se pudo analizar el archivo, intente más tarde"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+}
+
+// Any file or version pointing to a key not clean blocks it
+func (f *FilesService) checkKeyScanStatus(key string) *ErrorRes {
+	notClean := bson.M{
+		"$in": bson.A{models.SCAN_PENDING, models.SCAN_INFECTED, models.SCAN_ERROR},
+	}
+	var file *models.File
+	err := filesModel.Use().FindOne(db.Ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"key": key, "scan_status": notClean},
+			bson.M{"versions": bson.M{
+				"$elemMatch": bson.M{"key": key, "scan_status": notClean},
+			}},
+		},
+	}).Decode(&file)
+	if err != nil && err.Error() != db.NO_SINGLE_DOCUMENT {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if file == nil {
+		return nil
+	}
+	if file.Key == key {
+		return f.checkScanStatus(file.ScanStatus)
+	}
+	for _, version := range file.Versions {
+		if version.Key == key {
+			return f.checkScanStatus(version.ScanStatus)
+		}
+	}
+	return nil
+}
+
+// Move an infected content to the quarantine prefix, the files,
+// versions and blob pointing to it get the new key
+func (f *FilesService) quarantine(key string) error {
+	if strings.HasPrefix(key, QUARANTINE_PREFIX) {
+		return nil
+	}
+	quarantineKey := QUARANTINE_PREFIX + key
+	location, err := fileStorage.CopyFile(key, quarantineKey)
+	if err != nil {
+		return err
+	}
+	_, err = filesModel.Use().UpdateMany(db.Ctx, bson.M{
+		"key": key,
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"key": quarantineKey,
+			"url": location,
+		},
+	}})
+	if err != nil {
+		return err
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: bson.A{bson.M{"version.key": key}},
+	})
+	_, err = filesModel.Use().UpdateMany(db.Ctx, bson.M{
+		"versions.key": key,
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"versions.$[version].key": quarantineKey,
+			"versions.$[version].url": location,
+		},
+	}}, opts)
+	if err != nil {
+		return err
+	}
+	_, err = blobsModel.Use().UpdateMany(db.Ctx, bson.M{
+		"key": key,
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"key": quarantineKey,
+			"url": location,
+		},
+	}})
+	if err != nil {
+		return err
+	}
+	return fileStorage.DeleteFile(key)
+}
+
+// Scan the content of the key and save the result on every file and
+// version sharing it. Infected contents are quarantined: they are
+// moved out of their key, no token is issued for them and only
+// their owners can delete them. The derived files are generated
+// once the content is clean
+func (f *FilesService) scanKey(key, mimeType string) error {
+	scanStatus := models.SCAN_CLEAN
+
+	content, err := fileStorage.GetFile(key)
+	if err != nil {
+		return err
+	}
+	result, err := fileScanner.Scan(content)
+	content.Close()
+	if err != nil {
+		scanStatus = models.SCAN_ERROR
+	} else if result.Infected {
+		scanStatus = models.SCAN_INFECTED
+		fmt.Printf("File %v quarantined: %v\n", key, result.Signature)
+	}
+
+	_, errUpdate := filesModel.Use().UpdateMany(db.Ctx, bson.M{
+		"key": key,
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"scan_status": scanStatus,
+		},
+	}})
+	if errUpdate != nil {
+		return errUpdate
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: bson.A{bson.M{"version.key": key}},
+	})
+	_, errUpdate = filesModel.Use().UpdateMany(db.Ctx, bson.M{
+		"versions.key": key,
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"versions.$[version].scan_status": scanStatus,
+		},
+	}}, opts)
+	if errUpdate != nil {
+		return errUpdate
+	}
+	switch scanStatus {
+	case models.SCAN_CLEAN:
+		f.queueDerived(key, mimeType)
+	case models.SCAN_INFECTED:
+		if errQuarantine := f.quarantine(key); errQuarantine != nil {
+			return errQuarantine
+		}
+	}
+	return err
+}
+
+// Scan in background, bounded by SCAN_CONCURRENCY
+func (f *FilesService) queueScan(key, mimeType string) {
+	go func() {
+		scanQueue <- struct{}{}
+		defer func() { <-scanQueue }()
+
+		if err := f.scanKey(key, mimeType); err != nil {
+			fmt.Printf("Error scanning file %v: %v\n", key, err)
+		}
+	}()
+}
+
+// Mark as pending the contents stored while scanning was disabled,
+// the worker scans them as the ones left by a restart
+func (f *FilesService) markUnscanned() error {
+	unscanned := bson.M{"$exists": false}
+	_, err := filesModel.Use().UpdateMany(db.Ctx, bson.M{
+		"scan_status": unscanned,
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"scan_status": models.SCAN_PENDING,
+		},
+	}})
+	if err != nil {
+		return err
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: bson.A{bson.M{"version.scan_status": unscanned}},
+	})
+	_, err = filesModel.Use().UpdateMany(db.Ctx, bson.M{
+		"versions": bson.M{"$elemMatch": bson.M{"scan_status": unscanned}},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"versions.$[version].scan_status": models.SCAN_PENDING,
+		},
+	}}, opts)
+	return err
+}
+
+// Scan again the contents that failed or were left pending by
+// a restart
+func (f *FilesService) rescanFiles() {
+	retry := bson.M{
+		"$in": bson.A{models.SCAN_PENDING, models.SCAN_ERROR},
+	}
+	before := primitive.NewDateTimeFromTime(time.Now().Add(-SCAN_RETRY_INTERVAL))
+
+	cursor, err := filesModel.Use().Find(db.Ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"scan_status": retry, "date": bson.M{"$lt": before}},
+			bson.M{"versions": bson.M{"$elemMatch": bson.M{
+				"scan_status": retry,
+				"date":        bson.M{"$lt": before},
+			}}},
+		},
+	}, options.Find().SetProjection(bson.M{
+		"key":         1,
+		"type":        1,
+		"scan_status": 1,
+		"date":        1,
+		"versions":    1,
+	}))
+	if err != nil {
+		fmt.Printf("Error rescanning files: %v\n", err)
+		return
+	}
+	var files []models.File
+	if err := cursor.All(db.Ctx, &files); err != nil {
+		fmt.Printf("Error rescanning files: %v\n", err)
+		return
+	}
+	isRetry := func(scanStatus string, date primitive.DateTime) bool {
+		return (scanStatus == models.SCAN_PENDING || scanStatus == models.SCAN_ERROR) &&
+			date < before
+	}
+	// Type of every key to scan
+	keys := make(map[string]string)
+	for _, file := range files {
+		if isRetry(file.ScanStatus, file.Date) {
+			keys[file.Key] = file.Type
+		}
+		for _, version := range file.Versions {
+			if isRetry(version.ScanStatus, version.Date) {
+				keys[version.Key] = version.Type
+			}
+		}
+	}
+	for key, mimeType := range keys {
+		if err := f.scanKey(key, mimeType); err != nil {
+			fmt.Printf("Error scanning file %v: %v\n", key, err)
+		}
+	}
+}
+
+func InitScanWorker() {
+	if fileScanner == nil {
+		return
+	}
+	service := NewFilesService()
+	if err := service.markUnscanned(); err != nil {
+		fmt.Printf("Error marking unscanned files: %v\n", err)
+	}
+	go func() {
+		for {
+			service.rescanFiles()
+			time.Sleep(SCAN_RETRY_INTERVAL)
+		}
+	}()
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/previews"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const SEARCH_DEFAULT_LIMIT = 20
+
+// Matches read to fill a page after the skipped results, the ones
+// the user can not read are left out. A page may come short when
+// most of the matches are not readable
+const SEARCH_MAX_CANDIDATES = 200
+
+// Letters of a term kept to match its other forms, the text index
+// stems the words so "evaluaciones" finds "evaluación"
+const SNIPPET_MIN_PREFIX = 4
+const SNIPPET_STEM_CUT = 3
+
+// Characters around the first match shown in the snippet
+const SNIPPET_SIZE = 80
+
+const TEXT_CONCURRENCY = 1
+
+var textQueue = make(chan struct{}, TEXT_CONCURRENCY)
+
+// SearchResult is a file matching the search and the part of its
+// text that matched, with the terms highlighted with <em>
+type SearchResult struct {
+	File    models.File
+	Snippet string
+	Score   float64
+}
+
+// Extract the text of the content in background, every file with
+// the key shares it
+func (f *FilesService) queueText(key string) {
+	ext := filepath.Ext(key)
+	if !previews.IsSearchable(ext) {
+		return
+	}
+	go func() {
+		textQueue <- struct{}{}
+		defer func() { <-textQueue }()
+
+		content, err := fileStorage.GetFile(key)
+		if err != nil {
+			fmt.Printf("Error extracting text of %v: %v\n", key, err)
+			return
+		}
+		text, err := previews.ExtractText(content, ext)
+		content.Close()
+		if err != nil {
+			fmt.Printf("Error extracting text of %v: %v\n", key, err)
+			return
+		}
+		if text == "" {
+			return
+		}
+		_, err = filesModel.Use().UpdateMany(db.Ctx, bson.M{
+			"key": key,
+		}, bson.D{{
+			Key: "$set",
+			Value: bson.M{
+				"content": text,
+			},
+		}})
+		if err != nil {
+			fmt.Printf("Error extracting text of %v: %v\n", key, err)
+		}
+	}()
+}
+
+// Lowercase and without accents, as the text index compares
+func foldRune(r rune) rune {
+	switch r = unicode.ToLower(r); r {
+	case 'á', 'à', 'ä':
+		return 'a'
+	case 'é', 'è', 'ë':
+		return 'e'
+	case 'í', 'ì', 'ï':
+		return 'i'
+	case 'ó', 'ò', 'ö':
+		return 'o'
+	case 'ú', 'ù', 'ü':
+		return 'u'
+	case 'ñ':
+		return 'n'
+	default:
+		return r
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Prefixes of the terms of the search, without the operators of
+// the text index. The end of the long terms is cut, so a word
+// matches the terms sharing its stem
+func searchTerms(search string) []string {
+	var terms []string
+	for _, term := range strings.Fields(search) {
+		term = strings.Trim(term, "\"-")
+		if term == "" {
+			continue
+		}
+		runes := []rune(term)
+		for i := range runes {
+			runes[i] = foldRune(runes[i])
+		}
+		if cut := len(runes) - SNIPPET_STEM_CUT; cut > SNIPPET_MIN_PREFIX {
+			runes = runes[:cut]
+		} else if len(runes) > SNIPPET_MIN_PREFIX {
+			runes = runes[:SNIPPET_MIN_PREFIX]
+		}
+		terms = append(terms, string(runes))
+	}
+	return terms
+}
+
+// The part of the text around the first word starting with a term,
+// the text is escaped and the words are wrapped in <em>
+func snippet(text string, terms []string) string {
+	runes := []rune(text)
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		folded[i] = foldRune(r)
+	}
+	// Length of the word at i if it starts with a term
+	matchAt := func(i int) int {
+		if i > 0 && isWordRune(folded[i-1]) {
+			return 0
+		}
+		for _, term := range terms {
+			termRunes := []rune(term)
+			if i+len(termRunes) > len(folded) || string(folded[i:i+len(termRunes)]) != term {
+				continue
+			}
+			end := i + len(termRunes)
+			for end < len(folded) && isWordRune(folded[end]) {
+				end++
+			}
+			return end - i
+		}
+		return 0
+	}
+
+	first := -1
+	for i := range folded {
+		if matchAt(i) > 0 {
+			first = i
+			break
+		}
+	}
+	if first == -1 {
+		return ""
+	}
+	start := first - SNIPPET_SIZE
+	if start < 0 {
+		start = 0
+	}
+	end := first + SNIPPET_SIZE
+	if end > len(runes) {
+		end = len(runes)
+	}
+	return writeSnippet(runes, start, end, matchAt)
+}
+
+// The start of the text, for the files matched by their title
+// or by forms of the words the terms do not share
+func leadSnippet(text string) string {
+	runes := []rune(text)
+	end := 2 * SNIPPET_SIZE
+	if end > len(runes) {
+		end = len(runes)
+	}
+	return writeSnippet(runes, 0, end, func(int) int { return 0 })
+}
+
+func writeSnippet(runes []rune, start, end int, matchAt func(int) int) string {
+	var result strings.Builder
+	if start > 0 {
+		result.WriteString("…")
+	}
+	for i := start; i < end; {
+		if n := matchAt(i); n > 0 && i+n <= end {
+			result.WriteString("<em>")
+			result.WriteString(html.EscapeString(string(runes[i : i+n])))
+			result.WriteString("</em>")
+			i += n
+			continue
+		}
+		result.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		result.WriteString("…")
+	}
+	return result.String()
+}
+
+// Search the titles, descriptions, tags and contents of the files
+// the user can read, the best matches first. Skip counts the
+// readable results of the previous pages
+func (f *FilesService) SearchFiles(query forms.SearchForm, claims *Claims) ([]SearchResult, *ErrorRes) {
+	idObjUser, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if query.Limit == 0 {
+		query.Limit = SEARCH_DEFAULT_LIMIT
+	}
+	filter := bson.M{
+		"$text": bson.M{
+			"$search": query.Search,
+		},
+		"status": true,
+		"$or": bson.A{
+			bson.M{"user": idObjUser},
+			bson.M{"permissions": bson.M{"$in": bson.A{"public", "public_classroom"}}},
+			bson.M{"shares.user": idObjUser},
+			bson.M{"shares.user_type": claims.UserType},
+		},
+	}
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{
+			"score":    score,
+			"versions": 0,
+		}).
+		SetSort(bson.M{"score": score}).
+		SetLimit(int64(query.Skip + SEARCH_MAX_CANDIDATES))
+	cursor, err := filesModel.Use().Find(db.Ctx, filter, opts)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	defer cursor.Close(db.Ctx)
+
+	terms := searchTerms(query.Search)
+	results := []SearchResult{}
+	skipped := 0
+	for len(results) < query.Limit && cursor.Next(db.Ctx) {
+		var match struct {
+			models.File `bson:",inline"`
+			Score       float64 `bson:"score"`
+		}
+		if err := cursor.Decode(&match); err != nil {
+			return nil, &ErrorRes{
+				Err:        err,
+				StatusCode: http.StatusServiceUnavailable,
+			}
+		}
+		// Classrooms are resolved file by file
+		if errRes := f.checkReadAccess(&match.File, claims); errRes != nil {
+			continue
+		}
+		if skipped < query.Skip {
+			skipped++
+			continue
+		}
+		text := snippet(match.Content, terms)
+		if text == "" {
+			text = snippet(match.Description, terms)
+		}
+		if text == "" {
+			text = leadSnippet(match.Content)
+		}
+		match.Content = ""
+		results = append(results, SearchResult{
+			File:    match.File,
+			Snippet: text,
+			Score:   match.Score,
+		})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return results, nil
+}
@@ -1,15 +1,21 @@
 package services
 
 import (
-	"github.com/CPU-commits/Intranet_BFiles/aws_s3"
 	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/scanner"
+	"github.com/CPU-commits/Intranet_BFiles/settings"
 	"github.com/CPU-commits/Intranet_BFiles/stack"
+	"github.com/CPU-commits/Intranet_BFiles/storage"
 )
 
 // Models
 var filesModel = new(models.FilesModel)
 
-var aws = aws_s3.NewAWSS3()
+var fileStorage = storage.NewStorage()
+var fileScanner = scanner.NewScanner(
+	settings.GetSettings().SCANNER_DRIVER,
+	settings.GetSettings().CLAMAV_ADDRESS,
+)
 var nats_service = stack.NewNats()
 
 // Error Response
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var userTypes = []string{
+	models.DIRECTOR,
+	models.DIRECTIVE,
+	models.TEACHER,
+	models.ATTORNEY,
+	models.STUDENT_DIRECTIVE,
+	models.STUDENT,
+}
+
+// A share targets an user or an user type, never both
+func (f *FilesService) getShareTarget(shareData forms.ShareForm) (bson.M, *models.FileShare, *ErrorRes) {
+	if (shareData.User == "") == (shareData.UserType == "") {
+		return nil, nil, &ErrorRes{
+			Err:        errors.New("debe indicar un usuario o un tipo de usuario"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	share := &models.FileShare{
+		Write: shareData.Write,
+		Date:  primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if shareData.User != "" {
+		idObjUser, err := primitive.ObjectIDFromHex(shareData.User)
+		if err != nil {
+			return nil, nil, &ErrorRes{
+				Err:        err,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		share.User = idObjUser
+		return bson.M{"user": idObjUser}, share, nil
+	}
+	for _, userType := range userTypes {
+		if userType == shareData.UserType {
+			share.UserType = userType
+			return bson.M{"user_type": userType}, share, nil
+		}
+	}
+	return nil, nil, &ErrorRes{
+		Err:        errors.New("el tipo de usuario no existe"),
+		StatusCode: http.StatusBadRequest,
+	}
+}
+
+func (f *FilesService) ShareFile(idFile, idUser string, shareData forms.ShareForm) *ErrorRes {
+	file, errRes := f.getUserFile(idFile, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	target, share, errRes := f.getShareTarget(shareData)
+	if errRes != nil {
+		return errRes
+	}
+	if share.User == file.User {
+		return &ErrorRes{
+			Err:        errors.New("no puede compartir un archivo consigo mismo"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	// Replace the previous grant of the same target
+	_, err := filesModel.Use().UpdateByID(db.Ctx, file.ID, bson.D{{
+		Key: "$pull",
+		Value: bson.M{
+			"shares": target,
+		},
+	}})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	_, err = filesModel.Use().UpdateByID(db.Ctx, file.ID, bson.D{{
+		Key: "$push",
+		Value: bson.M{
+			"shares": share,
+		},
+	}})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return nil
+}
+
+func (f *FilesService) UnshareFile(idFile, idUser string, shareData forms.ShareForm) *ErrorRes {
+	file, errRes := f.getUserFile(idFile, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	target, _, errRes := f.getShareTarget(shareData)
+	if errRes != nil {
+		return errRes
+	}
+	result, err := filesModel.Use().UpdateByID(db.Ctx, file.ID, bson.D{{
+		Key: "$pull",
+		Value: bson.M{
+			"shares": target,
+		},
+	}})
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if result.ModifiedCount == 0 {
+		return &ErrorRes{
+			Err:        errors.New("el archivo no está compartido con este destinatario"),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return nil
+}
+
+// Files of other users shared with the user or with its user type
+func (f *FilesService) GetSharedFiles(claims *Claims) ([]models.File, *ErrorRes) {
+	idObjUser, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	var files []models.File
+
+	opts := options.Find().SetSort(bson.D{{
+		Key:   "date",
+		Value: -1,
+	}}).SetProjection(bson.M{
+		"shares":   0,
+		"versions": 0,
+		"content":  0,
+	})
+	cursor, err := filesModel.Use().Find(db.Ctx, bson.M{
+		"status": true,
+		"user": bson.M{
+			"$ne": idObjUser,
+		},
+		"$or": bson.A{
+			bson.M{"shares.user": idObjUser},
+			bson.M{"shares.user_type": claims.UserType},
+		},
+	}, opts)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if err := cursor.All(db.Ctx, &files); err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return files, nil
+}
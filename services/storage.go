@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/CPU-commits/Intranet_BFiles/storage"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+)
+
+var storageService *StorageService
+
+type StorageService struct{}
+
+// Serve the files of the backends without presigned URLs (local and memory)
+func (s *StorageService) GetFile(key, expires, signature string) (io.ReadCloser, string, *ErrorRes) {
+	if !storage.VerifySignature(key, expires, signature) {
+		return nil, "", &ErrorRes{
+			Err:        errors.New("el enlace es inválido o ha expirado"),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	file, err := fileStorage.GetFile(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, "", &ErrorRes{
+				Err:        err,
+				StatusCode: http.StatusNotFound,
+			}
+		}
+		return nil, "", &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return file, storage.ContentType(key), nil
+}
+
+// Receive the direct uploads of the backends without presigned URLs
+func (s *StorageService) PutFile(
+	key,
+	expires,
+	signature,
+	sizeStr,
+	contentType string,
+	file io.Reader,
+) *ErrorRes {
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil || !storage.VerifyUploadSignature(key, contentType, size, expires, signature) {
+		return &ErrorRes{
+			Err:        errors.New("el enlace es inválido o ha expirado"),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	limitedFile := utils.NewLimitedReader(file, size)
+	_, err = fileStorage.PutFile(key, limitedFile)
+	if limitedFile.Exceeded() || (err == nil && limitedFile.Size() != size) {
+		fileStorage.DeleteFile(key)
+		return &ErrorRes{
+			Err:        fmt.Errorf("el archivo debe pesar %v bytes", size),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return nil
+}
+
+func NewStorageService() *StorageService {
+	if storageService == nil {
+		storageService = &StorageService{}
+	}
+	return storageService
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/settings"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const TRASH_PURGE_INTERVAL = time.Hour
+
+func (f *FilesService) getTrashedFile(idFile, idUser string) (*models.File, *ErrorRes) {
+	file, errRes := f.getFile(idFile)
+	if errRes != nil {
+		return nil, errRes
+	}
+	if file.User.Hex() != idUser {
+		return nil, &ErrorRes{
+			Err:        errors.New("el archivo le pertenece a otro usuario"),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	if file.Status || file.DeletedAt == 0 {
+		return nil, &ErrorRes{
+			Err:        errors.New("el archivo no está en la papelera"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	return file, nil
+}
+
+// Delete the document of the file and release its contents. The
+// document goes first, a failure afterwards leaks contents instead
+// of leaving a file without them, and a file purged twice at the
+// same time is released only once
+func (f *FilesService) purgeFile(file *models.File) error {
+	result, err := filesModel.Use().DeleteOne(db.Ctx, bson.D{{
+		Key:   "_id",
+		Value: file.ID,
+	}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return nil
+	}
+	if err := blobsService.release(file.Key); err != nil {
+		return err
+	}
+	for _, version := range file.Versions {
+		if err := blobsService.release(version.Key); err != nil {
+			return err
+		}
+	}
+	// Free the space of the owner
+	size := file.Size
+	for _, version := range file.Versions {
+		size += version.Size
+	}
+	return quotasService.release(file.User, size)
+}
+
+func (f *FilesService) GetTrash(idUser string) ([]models.File, *ErrorRes) {
+	idObjUser, err := primitive.ObjectIDFromHex(idUser)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	var files []models.File
+
+	opts := options.Find().SetSort(bson.D{{
+		Key:   "deleted_at",
+		Value: -1,
+	}})
+	cursor, err := filesModel.Use().Find(db.Ctx, bson.M{
+		"user":   idObjUser,
+		"status": false,
+		"deleted_at": bson.M{
+			"$exists": true,
+		},
+	}, opts)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if err := cursor.All(db.Ctx, &files); err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return files, nil
+}
+
+func (f *FilesService) RestoreFile(idFile, idUser string) *ErrorRes {
+	file, errRes := f.getTrashedFile(idFile, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.M{"status": true}},
+		{Key: "$unset", Value: bson.M{"deleted_at": ""}},
+	}
+	// If the folder was deleted meanwhile the file goes to the root
+	parent := file.Parent
+	if !file.Parent.IsZero() {
+		if _, errRes := foldersService.getFolder(file.Parent.Hex(), idUser); errRes != nil {
+			parent = primitive.NilObjectID
+			update = bson.D{
+				{Key: "$set", Value: bson.M{"status": true}},
+				{Key: "$unset", Value: bson.M{"deleted_at": "", "parent": ""}},
+			}
+		}
+	}
+	if errRes := f.checkFilename(file.Filename, file.User, parent); errRes != nil {
+		return errRes
+	}
+	_, err := filesModel.Use().UpdateByID(db.Ctx, file.ID, update)
+	if err != nil {
+		return f.filenameError(err)
+	}
+	return nil
+}
+
+func (f *FilesService) PurgeFile(idFile, idUser string) *ErrorRes {
+	file, errRes := f.getTrashedFile(idFile, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	if err := f.purgeFile(file); err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return nil
+}
+
+func (f *FilesService) purgeExpiredFiles() {
+	retention := time.Duration(settings.GetSettings().TRASH_RETENTION) * 24 * time.Hour
+
+	var files []models.File
+	cursor, err := filesModel.Use().Find(db.Ctx, bson.M{
+		"status": false,
+		"deleted_at": bson.M{
+			"$lt": primitive.NewDateTimeFromTime(time.Now().Add(-retention)),
+		},
+	})
+	if err != nil {
+		fmt.Printf("Error purging trash: %v\n", err)
+		return
+	}
+	if err := cursor.All(db.Ctx, &files); err != nil {
+		fmt.Printf("Error purging trash: %v\n", err)
+		return
+	}
+	for i := range files {
+		if err := f.purgeFile(&files[i]); err != nil {
+			fmt.Printf("Error purging file %v: %v\n", files[i].ID.Hex(), err)
+		}
+	}
+}
+
+// Delete the files that exceeded the retention of the trash periodically
+func InitTrashPurger() {
+	go func() {
+		service := NewFilesService()
+		for {
+			service.purgeExpiredFiles()
+			time.Sleep(TRASH_PURGE_INTERVAL)
+		}
+	}()
+}
@@ -5,6 +5,8 @@ type FileNats struct {
 	Filename string `json:"filename"`
 	Mimetype string `json:"mime-type"`
 	Key      string `json:"key"`
+	// Classroom owner of the file
+	Classroom string `json:"classroom"`
 }
 
 type FilePermission struct {
@@ -0,0 +1,525 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/forms"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// S3 requires at least 5MB for every part but the last one
+	UPLOAD_CHUNK_SIZE = 5242880
+	// Abandoned sessions expire after this time without new parts
+	UPLOAD_EXPIRATION     = 24 * time.Hour
+	UPLOAD_CLEAN_INTERVAL = time.Hour
+)
+
+var uploadsModel = new(models.UploadsModel)
+
+var uploadsService *UploadsService
+
+type UploadsService struct{}
+
+func (u *UploadsService) getUpload(idUpload, idUser string) (*models.Upload, *ErrorRes) {
+	idObjUpload, err := primitive.ObjectIDFromHex(idUpload)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	var upload *models.Upload
+	cursor := uploadsModel.Use().FindOne(db.Ctx, bson.D{{
+		Key:   "_id",
+		Value: idObjUpload,
+	}})
+	if err := cursor.Decode(&upload); err != nil {
+		if err.Error() == db.NO_SINGLE_DOCUMENT {
+			return nil, &ErrorRes{
+				Err:        errors.New("la subida no existe o ha expirado"),
+				StatusCode: http.StatusNotFound,
+			}
+		}
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if upload.User.Hex() != idUser {
+		return nil, &ErrorRes{
+			Err:        errors.New("la subida le pertenece a otro usuario"),
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	return upload, nil
+}
+
+func (u *UploadsService) GetUpload(idUpload, idUser string) (*models.Upload, *ErrorRes) {
+	return u.getUpload(idUpload, idUser)
+}
+
+func (u *UploadsService) InitUpload(
+	uploadData forms.UploadForm,
+	limits *utils.FileLimits,
+	claims *Claims,
+) (*models.Upload, *ErrorRes) {
+	idUser := claims.ID
+	idObjUser, err := primitive.ObjectIDFromHex(idUser)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if uploadData.Size > limits.MaxSize {
+		return nil, &ErrorRes{
+			Err: fmt.Errorf(
+				"el archivo %v excede el tamaño máximo de %v",
+				uploadData.Filename,
+				limits.MaxSizeStr,
+			),
+			StatusCode: http.StatusRequestEntityTooLarge,
+		}
+	}
+	usage, errRes := quotasService.getUserUsage(idObjUser, claims.UserType)
+	if errRes != nil {
+		return nil, errRes
+	}
+	if usage.Remaining != UNLIMITED_QUOTA && uploadData.Size > usage.Remaining {
+		return nil, quotasService.quotaError(usage)
+	}
+	// The type is checked again with the content of the first part
+	errRes = fileTypes.checkFileType(
+		uploadData.Filename,
+		utils.GetMimeType(filepath.Ext(uploadData.Filename)),
+		claims.UserType,
+		CHANNEL_HTTP,
+	)
+	if errRes != nil {
+		return nil, errRes
+	}
+	ext := strings.Split(uploadData.Filename, ".")
+	filename := fmt.Sprintf("%s.%s", uploadData.Title, ext[len(ext)-1])
+	parent, errRes := foldersService.getParent(uploadData.Folder, idUser)
+	if errRes != nil {
+		return nil, errRes
+	}
+	// With rename the name is resolved when completing
+	if !uploadData.Rename {
+		if errRes := filesService.checkFilename(filename, idObjUser, parent); errRes != nil {
+			return nil, errRes
+		}
+	}
+	// Init multipart upload
+	key, uploadID, err := fileStorage.CreateMultipartUpload(uploadData.Filename, idUser)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	upload, err := uploadsModel.NewModel(
+		uploadData.Title,
+		filename,
+		uploadData.Filename,
+		key,
+		uploadID,
+		idUser,
+		uploadData.Size,
+		UPLOAD_CHUNK_SIZE,
+		time.Now().Add(UPLOAD_EXPIRATION),
+	)
+	if err != nil {
+		fileStorage.AbortMultipartUpload(key, uploadID)
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	upload.Rename = uploadData.Rename
+	upload.UserType = claims.UserType
+	upload.Parent = parent
+	inserted, err := uploadsModel.Use().InsertOne(db.Ctx, upload)
+	if err != nil {
+		fileStorage.AbortMultipartUpload(key, uploadID)
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	upload.ID = inserted.InsertedID.(primitive.ObjectID)
+	return upload, nil
+}
+
+func (u *UploadsService) UploadPart(
+	idUpload string,
+	claims *Claims,
+	offset int64,
+	body io.Reader,
+) (*models.Upload, *ErrorRes) {
+	upload, errRes := u.getUpload(idUpload, claims.ID)
+	if errRes != nil {
+		return nil, errRes
+	}
+	if offset != upload.Offset {
+		return nil, &ErrorRes{
+			Err:        fmt.Errorf("el offset de la subida es %v", upload.Offset),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	if upload.Offset >= upload.Size {
+		return nil, &ErrorRes{
+			Err:        errors.New("la subida ya recibió todo el archivo"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	// Every part must be a complete chunk, except the last one
+	expectedSize := upload.ChunkSize
+	if upload.Size-upload.Offset < expectedSize {
+		expectedSize = upload.Size - upload.Offset
+	}
+	limitedBody := utils.NewLimitedReader(body, expectedSize)
+	part, err := io.ReadAll(limitedBody)
+	if limitedBody.Exceeded() || (err == nil && int64(len(part)) != expectedSize) {
+		return nil, &ErrorRes{
+			Err:        fmt.Errorf("la parte debe pesar %v bytes", expectedSize),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	partNumber := len(upload.Parts) + 1
+	// The type is sniffed from the first part
+	mimeType := upload.Type
+	if partNumber == 1 {
+		head := part
+		if len(head) > utils.SNIFF_SIZE {
+			head = head[:utils.SNIFF_SIZE]
+		}
+		mimeType, err = utils.ResolveMimeType(filepath.Ext(upload.OriginalFilename), head)
+		if err != nil {
+			u.abortUpload(upload)
+			return nil, &ErrorRes{
+				Err:        err,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		errRes := fileTypes.checkFileType(
+			upload.OriginalFilename,
+			mimeType,
+			claims.UserType,
+			CHANNEL_HTTP,
+		)
+		if errRes != nil {
+			u.abortUpload(upload)
+			return nil, errRes
+		}
+	}
+	hashState, err := u.updateHash(upload.HashState, part)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	etag, err := fileStorage.UploadPart(
+		upload.Key,
+		upload.UploadID,
+		partNumber,
+		bytes.NewReader(part),
+	)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	// Only moves forward if no other request has sent the same part
+	uploadPart := models.UploadPart{
+		Number: partNumber,
+		ETag:   etag,
+		Size:   expectedSize,
+	}
+	expiresAt := time.Now().Add(UPLOAD_EXPIRATION)
+	result, err := uploadsModel.Use().UpdateOne(db.Ctx, bson.D{
+		{Key: "_id", Value: upload.ID},
+		{Key: "offset", Value: upload.Offset},
+	}, bson.D{
+		{Key: "$push", Value: bson.M{"parts": uploadPart}},
+		{Key: "$set", Value: bson.M{
+			"offset":     upload.Offset + expectedSize,
+			"type":       mimeType,
+			"hash_state": hashState,
+			"expires_at": primitive.NewDateTimeFromTime(expiresAt),
+		}},
+	})
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if result.ModifiedCount == 0 {
+		return nil, &ErrorRes{
+			Err:        errors.New("la parte ya fue subida por otra petición"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	upload.Parts = append(upload.Parts, uploadPart)
+	upload.Offset += expectedSize
+	upload.Type = mimeType
+	upload.HashState = hashState
+	upload.ExpiresAt = primitive.NewDateTimeFromTime(expiresAt)
+	return upload, nil
+}
+
+// The SHA-256 state is saved between parts, so the checksum
+// is computed without reading the file again
+func (u *UploadsService) updateHash(state []byte, part []byte) ([]byte, error) {
+	hash := sha256.New()
+	if len(state) > 0 {
+		if err := hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+			return nil, err
+		}
+	}
+	hash.Write(part)
+	return hash.(encoding.BinaryMarshaler).MarshalBinary()
+}
+
+func (u *UploadsService) getChecksum(state []byte) (string, error) {
+	hash := sha256.New()
+	if err := hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Join the parts and store the content, the session keeps it until
+// the file is created
+func (u *UploadsService) storeUpload(upload *models.Upload) (*storedFile, *ErrorRes) {
+	checksum, err := u.getChecksum(upload.HashState)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	if upload.Location != "" {
+		return &storedFile{
+			Location: upload.Location,
+			Key:      upload.Key,
+			Size:     upload.Size,
+			Checksum: checksum,
+			Type:     upload.Type,
+		}, nil
+	}
+	// The space is taken before joining the parts, so the session
+	// can be completed later if the quota is exceeded
+	usage, errRes := quotasService.getUserUsage(upload.User, upload.UserType)
+	if errRes != nil {
+		return nil, errRes
+	}
+	if errRes := quotasService.consume(upload.User, upload.Size, usage); errRes != nil {
+		return nil, errRes
+	}
+	etags := make([]string, len(upload.Parts))
+	for _, part := range upload.Parts {
+		etags[part.Number-1] = part.ETag
+	}
+	location, err := fileStorage.CompleteMultipartUpload(upload.Key, upload.UploadID, etags)
+	if err != nil {
+		quotasService.release(upload.User, upload.Size)
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	stored := &storedFile{
+		Location: location,
+		Key:      upload.Key,
+		Size:     upload.Size,
+		Checksum: checksum,
+		Type:     upload.Type,
+	}
+	if err := blobsService.store(stored); err != nil {
+		fileStorage.DeleteFile(upload.Key)
+		quotasService.release(upload.User, upload.Size)
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	_, err = uploadsModel.Use().UpdateOne(db.Ctx, bson.D{{
+		Key:   "_id",
+		Value: upload.ID,
+	}}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"key":      stored.Key,
+			"location": stored.Location,
+		},
+	}})
+	if err != nil {
+		filesService.discardStored(stored, upload.User)
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return stored, nil
+}
+
+func (u *UploadsService) CompleteUpload(idUpload, idUser string) (*models.File, *ErrorRes) {
+	upload, errRes := u.getUpload(idUpload, idUser)
+	if errRes != nil {
+		return nil, errRes
+	}
+	if upload.Offset != upload.Size {
+		return nil, &ErrorRes{
+			Err:        fmt.Errorf("faltan %v bytes por subir", upload.Size-upload.Offset),
+			StatusCode: http.StatusConflict,
+		}
+	}
+	filename, errRes := filesService.resolveFilename(
+		upload.Filename,
+		upload.User,
+		upload.Parent,
+		upload.Rename,
+	)
+	if errRes != nil {
+		return nil, errRes
+	}
+	stored, errRes := u.storeUpload(upload)
+	if errRes != nil {
+		return nil, errRes
+	}
+	// A failure from here keeps the stored content in the session,
+	// so completing it again only creates the file
+	fileModel, err := filesModel.NewModel(
+		filename,
+		stored.Key,
+		stored.Location,
+		upload.Title,
+		stored.Type,
+		idUser,
+		"private",
+	)
+	if err != nil {
+		return nil, &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	fileModel.Size = stored.Size
+	fileModel.Checksum = stored.Checksum
+	fileModel.Parent = upload.Parent
+	fileModel.ScanStatus = filesService.initialScanStatus()
+	newFile, errRes := filesService.uploadFileDB(fileModel, upload.Rename)
+	if errRes != nil {
+		return nil, errRes
+	}
+	_, err = uploadsModel.Use().DeleteOne(db.Ctx, bson.D{{
+		Key:   "_id",
+		Value: upload.ID,
+	}})
+	if err != nil {
+		fmt.Printf("Error deleting upload %v: %v\n", upload.ID.Hex(), err)
+	}
+	filesService.processContent(newFile.Key, newFile.Type)
+	return newFile, nil
+}
+
+func (u *UploadsService) abortUpload(upload *models.Upload) error {
+	// The parts were already joined, the content is released
+	if upload.Location != "" {
+		filesService.discardStored(&storedFile{
+			Key:  upload.Key,
+			Size: upload.Size,
+		}, upload.User)
+	} else {
+		err := fileStorage.AbortMultipartUpload(upload.Key, upload.UploadID)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := uploadsModel.Use().DeleteOne(db.Ctx, bson.D{{
+		Key:   "_id",
+		Value: upload.ID,
+	}})
+	return err
+}
+
+func (u *UploadsService) AbortUpload(idUpload, idUser string) *ErrorRes {
+	upload, errRes := u.getUpload(idUpload, idUser)
+	if errRes != nil {
+		return errRes
+	}
+	if err := u.abortUpload(upload); err != nil {
+		return &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	return nil
+}
+
+func (u *UploadsService) cleanExpiredUploads() {
+	var uploads []models.Upload
+	cursor, err := uploadsModel.Use().Find(db.Ctx, bson.D{{
+		Key: "expires_at",
+		Value: bson.M{
+			"$lt": primitive.NewDateTimeFromTime(time.Now()),
+		},
+	}})
+	if err != nil {
+		fmt.Printf("Error cleaning uploads: %v\n", err)
+		return
+	}
+	if err := cursor.All(db.Ctx, &uploads); err != nil {
+		fmt.Printf("Error cleaning uploads: %v\n", err)
+		return
+	}
+	for i := range uploads {
+		if err := u.abortUpload(&uploads[i]); err != nil {
+			fmt.Printf("Error cleaning upload %v: %v\n", uploads[i].ID.Hex(), err)
+		}
+	}
+}
+
+// Abort the abandoned sessions periodically
+func InitUploadsCleaner() {
+	go func() {
+		service := NewUploadsService()
+		for {
+			service.cleanExpiredUploads()
+			service.cleanExpiredDirectUploads()
+			time.Sleep(UPLOAD_CLEAN_INTERVAL)
+		}
+	}()
+}
+
+func NewUploadsService() *UploadsService {
+	if uploadsService == nil {
+		uploadsService = &UploadsService{}
+	}
+	return uploadsService
+}
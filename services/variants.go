@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/CPU-commits/Intranet_BFiles/db"
+	"github.com/CPU-commits/Intranet_BFiles/models"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	VARIANT_THUMBNAIL = "thumbnail"
+	VARIANT_MEDIUM    = "medium"
+)
+
+const VARIANTS_CONCURRENCY = 2
+
+// Max width or height of every variant
+var imageVariants = []struct {
+	Name    string
+	MaxSize int
+}{
+	{Name: VARIANT_THUMBNAIL, MaxSize: 200},
+	{Name: VARIANT_MEDIUM, MaxSize: 800},
+}
+
+var variantsQueue = make(chan struct{}, VARIANTS_CONCURRENCY)
+
+// Variants are stored next to the original, "<key>_<variant>.<ext>"
+func variantKey(key, name, ext string) string {
+	return fmt.Sprintf("%s_%s%s", strings.TrimSuffix(key, filepath.Ext(key)), name, ext)
+}
+
+func isVariant(name string) bool {
+	for _, variant := range imageVariants {
+		if variant.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Resize the image of the key and save the variants on every file
+// sharing it. Images smaller than a variant have not that variant
+func (f *FilesService) generateVariants(key string) error {
+	content, err := fileStorage.GetFile(key)
+	if err != nil {
+		return err
+	}
+	img, err := utils.DecodeImage(content)
+	content.Close()
+	if err != nil {
+		return err
+	}
+
+	variants := []models.FileVariant{}
+	bounds := img.Bounds()
+	for _, variant := range imageVariants {
+		if bounds.Dx() <= variant.MaxSize && bounds.Dy() <= variant.MaxSize {
+			continue
+		}
+		resized := utils.ResizeImage(img, variant.MaxSize)
+		data, ext, err := utils.EncodeImage(resized)
+		if err != nil {
+			return err
+		}
+		keyVariant := variantKey(key, variant.Name, ext)
+		location, err := fileStorage.PutFile(keyVariant, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		variants = append(variants, models.FileVariant{
+			Name:   variant.Name,
+			Key:    keyVariant,
+			URL:    location,
+			Type:   utils.GetMimeType(ext),
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+			Size:   int64(len(data)),
+		})
+	}
+	return f.setVariants(key, variants)
+}
+
+func (f *FilesService) setVariants(key string, variants []models.FileVariant) error {
+	_, err := filesModel.Use().UpdateMany(db.Ctx, bson.M{
+		"key": key,
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.M{
+			"variants": variants,
+		},
+	}})
+	return err
+}
+
+// Generate the variants in background, bounded by VARIANTS_CONCURRENCY.
+// Contents already processed for another file are reused
+func (f *FilesService) queueVariants(key, mimeType string) {
+	if !utils.IsResizableImage(mimeType) {
+		return
+	}
+	go func() {
+		variantsQueue <- struct{}{}
+		defer func() { <-variantsQueue }()
+
+		var file *models.File
+		err := filesModel.Use().FindOne(db.Ctx, bson.M{
+			"key":      key,
+			"variants": bson.M{"$exists": true},
+		}).Decode(&file)
+		if err == nil {
+			err = f.setVariants(key, file.Variants)
+		} else {
+			err = f.generateVariants(key)
+		}
+		if err != nil {
+			fmt.Printf("Error generating variants of %v: %v\n", key, err)
+		}
+	}()
+}
+
+// Key to serve, files without the variant serve the original
+func (f *FilesService) getVariantKey(file *models.File, variant string) (string, *ErrorRes) {
+	if variant == "" {
+		return file.Key, nil
+	}
+	if !isVariant(variant) {
+		return "", &ErrorRes{
+			Err:        fmt.Errorf("la variante %s no existe", variant),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if fileVariant := file.GetVariant(variant); fileVariant != nil {
+		return fileVariant.Key, nil
+	}
+	return file.Key, nil
+}
+
+// Same as getVariantKey, for the channels knowing only the key
+func (f *FilesService) getKeyVariant(key, variant string) (string, *ErrorRes) {
+	if variant == "" {
+		return key, nil
+	}
+	var file *models.File
+	err := filesModel.Use().FindOne(db.Ctx, bson.M{
+		"key": key,
+	}).Decode(&file)
+	if err != nil && err.Error() != db.NO_SINGLE_DOCUMENT {
+		return "", &ErrorRes{
+			Err:        err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
+	if file == nil {
+		file = &models.File{Key: key}
+	}
+	return f.getVariantKey(file, variant)
+}
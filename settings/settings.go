@@ -23,16 +23,48 @@ type settings struct {
 	NATS_HOST           string
 	AWS_BUCKET          string
 	AWS_REGION          string
+	STORAGE_DRIVER      string
+	STORAGE_PATH        string
+	STORAGE_URL         string
+	SCANNER_DRIVER      string
+	CLAMAV_ADDRESS      string
+	FILE_TYPES_POLICY   string
+	PDF_RENDERER        string
+	OFFICE_CONVERTER    string
+	PDF_TEXT_EXTRACTOR  string
+	TRASH_RETENTION     int
+	MIGRATE_ON_START    bool
+	QUOTA_DIRECTOR      int64
+	QUOTA_DIRECTIVE     int64
+	QUOTA_TEACHER       int64
 	CLIENT_URL          string
 	NODE_ENV            string
 }
 
+// Quotas are configured in MB, -1 is unlimited
+func getQuota(env string, defaultMB int64) int64 {
+	quota, err := strconv.ParseInt(os.Getenv(env), 10, 64)
+	if err != nil {
+		quota = defaultMB
+	}
+	if quota < 0 {
+		return -1
+	}
+	return quota * 1024 * 1024
+}
+
 func newSettings() *settings {
 	mongoPort, err := strconv.Atoi(os.Getenv("MONGO_PORT"))
 	if err != nil {
 		panic(err)
 	}
 
+	// Days a file stays in the trash
+	trashRetention, err := strconv.Atoi(os.Getenv("TRASH_RETENTION"))
+	if err != nil {
+		trashRetention = 30
+	}
+
 	return &settings{
 		JWT_SECRET_KEY:      os.Getenv("JWT_SECRET_KEY"),
 		MONGO_DB:            os.Getenv("MONGO_DB"),
@@ -43,9 +75,23 @@ func newSettings() *settings {
 		NATS_HOST:           os.Getenv("NATS_HOST"),
 		AWS_BUCKET:          os.Getenv("AWS_BUCKET"),
 		AWS_REGION:          os.Getenv("AWS_REGION"),
+		STORAGE_DRIVER:      os.Getenv("STORAGE_DRIVER"),
+		STORAGE_PATH:        os.Getenv("STORAGE_PATH"),
+		STORAGE_URL:         os.Getenv("STORAGE_URL"),
+		SCANNER_DRIVER:      os.Getenv("SCANNER_DRIVER"),
+		CLAMAV_ADDRESS:      os.Getenv("CLAMAV_ADDRESS"),
+		FILE_TYPES_POLICY:   os.Getenv("FILE_TYPES_POLICY"),
+		PDF_RENDERER:        os.Getenv("PDF_RENDERER"),
+		OFFICE_CONVERTER:    os.Getenv("OFFICE_CONVERTER"),
+		PDF_TEXT_EXTRACTOR:  os.Getenv("PDF_TEXT_EXTRACTOR"),
 		CLIENT_URL:          os.Getenv("CLIENT_URL"),
 		NODE_ENV:            os.Getenv("NODE_ENV"),
 		MONGO_PORT:          mongoPort,
+		TRASH_RETENTION:     trashRetention,
+		MIGRATE_ON_START:    os.Getenv("MIGRATE_ON_START") != "false",
+		QUOTA_DIRECTOR:      getQuota("QUOTA_DIRECTOR", 5120),
+		QUOTA_DIRECTIVE:     getQuota("QUOTA_DIRECTIVE", 2048),
+		QUOTA_TEACHER:       getQuota("QUOTA_TEACHER", 1024),
 	}
 }
 
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"github.com/google/uuid"
+)
+
+type LocalStorage struct {
+	root string
+}
+
+func (local *LocalStorage) path(key string) string {
+	return filepath.Join(local.root, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+func (local *LocalStorage) UploadFile(file io.Reader, filename, idUser string) (string, string, error) {
+	key := utils.NewFileKey(idUser, filename)
+	location, err := local.PutFile(key, file)
+	if err != nil {
+		return "", "", err
+	}
+	return location, key, nil
+}
+
+func (local *LocalStorage) PutFile(key string, file io.Reader) (string, error) {
+	path := local.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", err
+	}
+	dst, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, file); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return settingsData.STORAGE_URL + SERVE_PATH + key, nil
+}
+
+func (local *LocalStorage) GetFile(key string) (io.ReadCloser, error) {
+	file, err := os.Open(local.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (local *LocalStorage) GetFileRange(key string, offset, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(local.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sectionReader{
+		Reader: io.NewSectionReader(file, offset, length),
+		Closer: file,
+	}, nil
+}
+
+func (local *LocalStorage) GetFileToken(key string) (string, error) {
+	if _, err := os.Stat(local.path(key)); err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return signedURL(key), nil
+}
+
+func (local *LocalStorage) GetUploadToken(key, contentType string, size int64) (string, error) {
+	return signedUploadURL(key, contentType, size), nil
+}
+
+func (local *LocalStorage) HeadFile(key string) (int64, string, error) {
+	info, err := os.Stat(local.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, "", ErrNotFound
+		}
+		return 0, "", err
+	}
+	return info.Size(), ContentType(key), nil
+}
+
+func (local *LocalStorage) CopyFile(src, dst string) (string, error) {
+	file, err := local.GetFile(src)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return local.PutFile(dst, file)
+}
+
+func (local *LocalStorage) DeleteFile(key string) error {
+	err := os.Remove(local.path(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	if root == "" {
+		root = "storage_files"
+	}
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		panic(err)
+	}
+	return &LocalStorage{
+		root: root,
+	}
+}
+
+func (local *LocalStorage) partsPath(uploadID string) string {
+	return filepath.Join(local.root, ".multipart", filepath.Base(uploadID))
+}
+
+func (local *LocalStorage) CreateMultipartUpload(filename, idUser string) (string, string, error) {
+	key := utils.NewFileKey(idUser, filename)
+	uploadID := uuid.New().String()
+	if err := os.MkdirAll(local.partsPath(uploadID), os.ModePerm); err != nil {
+		return "", "", err
+	}
+	return key, uploadID, nil
+}
+
+func (local *LocalStorage) UploadPart(key, uploadID string, partNumber int, part io.ReadSeeker) (string, error) {
+	dir := local.partsPath(uploadID)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return "", ErrUploadNotFound
+	}
+	data, err := io.ReadAll(part)
+	if err != nil {
+		return "", err
+	}
+	err = os.WriteFile(filepath.Join(dir, strconv.Itoa(partNumber)), data, 0644)
+	if err != nil {
+		return "", err
+	}
+	return partETag(data), nil
+}
+
+func (local *LocalStorage) CompleteMultipartUpload(key, uploadID string, etags []string) (string, error) {
+	dir := local.partsPath(uploadID)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return "", ErrUploadNotFound
+	}
+	path := local.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", err
+	}
+	dst, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+	for i := range etags {
+		part, err := os.Open(filepath.Join(dir, strconv.Itoa(i+1)))
+		if err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		_, err = io.Copy(dst, part)
+		part.Close()
+		if err != nil {
+			os.Remove(path)
+			return "", err
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	return settingsData.STORAGE_URL + SERVE_PATH + key, nil
+}
+
+func (local *LocalStorage) AbortMultipartUpload(key, uploadID string) error {
+	return os.RemoveAll(local.partsPath(uploadID))
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+	"github.com/google/uuid"
+)
+
+// MemoryStorage keeps every file in memory, intended for CI and tests
+type MemoryStorage struct {
+	lock    sync.RWMutex
+	files   map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func (memory *MemoryStorage) UploadFile(file io.Reader, filename, idUser string) (string, string, error) {
+	key := utils.NewFileKey(idUser, filename)
+	location, err := memory.PutFile(key, file)
+	if err != nil {
+		return "", "", err
+	}
+	return location, key, nil
+}
+
+func (memory *MemoryStorage) PutFile(key string, file io.Reader) (string, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+
+	memory.lock.Lock()
+	memory.files[key] = data
+	memory.lock.Unlock()
+	return settingsData.STORAGE_URL + SERVE_PATH + key, nil
+}
+
+func (memory *MemoryStorage) GetFile(key string) (io.ReadCloser, error) {
+	memory.lock.RLock()
+	defer memory.lock.RUnlock()
+	data, ok := memory.files[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (memory *MemoryStorage) GetFileRange(key string, offset, length int64) (io.ReadCloser, error) {
+	memory.lock.RLock()
+	defer memory.lock.RUnlock()
+	data, ok := memory.files[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	reader := io.NewSectionReader(bytes.NewReader(data), offset, length)
+	return io.NopCloser(reader), nil
+}
+
+func (memory *MemoryStorage) GetFileToken(key string) (string, error) {
+	memory.lock.RLock()
+	defer memory.lock.RUnlock()
+	if _, ok := memory.files[key]; !ok {
+		return "", ErrNotFound
+	}
+	return signedURL(key), nil
+}
+
+func (memory *MemoryStorage) GetUploadToken(key, contentType string, size int64) (string, error) {
+	return signedUploadURL(key, contentType, size), nil
+}
+
+func (memory *MemoryStorage) HeadFile(key string) (int64, string, error) {
+	memory.lock.RLock()
+	defer memory.lock.RUnlock()
+	data, ok := memory.files[key]
+	if !ok {
+		return 0, "", ErrNotFound
+	}
+	return int64(len(data)), ContentType(key), nil
+}
+
+func (memory *MemoryStorage) CopyFile(src, dst string) (string, error) {
+	memory.lock.Lock()
+	data, ok := memory.files[src]
+	if ok {
+		memory.files[dst] = data
+	}
+	memory.lock.Unlock()
+	if !ok {
+		return "", ErrNotFound
+	}
+	return settingsData.STORAGE_URL + SERVE_PATH + dst, nil
+}
+
+func (memory *MemoryStorage) DeleteFile(key string) error {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	delete(memory.files, key)
+	return nil
+}
+
+func (memory *MemoryStorage) CreateMultipartUpload(filename, idUser string) (string, string, error) {
+	key := utils.NewFileKey(idUser, filename)
+	uploadID := uuid.New().String()
+
+	memory.lock.Lock()
+	memory.uploads[uploadID] = make(map[int][]byte)
+	memory.lock.Unlock()
+	return key, uploadID, nil
+}
+
+func (memory *MemoryStorage) UploadPart(key, uploadID string, partNumber int, part io.ReadSeeker) (string, error) {
+	data, err := io.ReadAll(part)
+	if err != nil {
+		return "", err
+	}
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	parts, ok := memory.uploads[uploadID]
+	if !ok {
+		return "", ErrUploadNotFound
+	}
+	parts[partNumber] = data
+	return partETag(data), nil
+}
+
+func (memory *MemoryStorage) CompleteMultipartUpload(key, uploadID string, etags []string) (string, error) {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	parts, ok := memory.uploads[uploadID]
+	if !ok {
+		return "", ErrUploadNotFound
+	}
+	var data []byte
+	for i := range etags {
+		data = append(data, parts[i+1]...)
+	}
+	memory.files[key] = data
+	delete(memory.uploads, uploadID)
+	return settingsData.STORAGE_URL + SERVE_PATH + key, nil
+}
+
+func (memory *MemoryStorage) AbortMultipartUpload(key, uploadID string) error {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	delete(memory.uploads, uploadID)
+	return nil
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		files:   make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+type sectionReader struct {
+	io.Reader
+	io.Closer
+}
+
+// RangeReader reads a stored file as an io.ReadSeeker, so it can
+// be served with http.ServeContent. The file is requested from the
+// storage on the first read after every seek
+type RangeReader struct {
+	storage Storage
+	key     string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+func (r *RangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.storage.GetFileRange(r.key, r.offset, r.size-r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *RangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("storage: negative position")
+	}
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return r.offset, nil
+}
+
+func (r *RangeReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+func NewRangeReader(storage Storage, key string, size int64) *RangeReader {
+	return &RangeReader{
+		storage: storage,
+		key:     key,
+		size:    size,
+	}
+}
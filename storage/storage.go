@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/CPU-commits/Intranet_BFiles/aws_s3"
+	"github.com/CPU-commits/Intranet_BFiles/settings"
+	"github.com/CPU-commits/Intranet_BFiles/utils"
+)
+
+const (
+	S3_DRIVER     = "s3"
+	LOCAL_DRIVER  = "local"
+	MEMORY_DRIVER = "memory"
+)
+
+// Route served by the files API for backends without presigned URLs
+const SERVE_PATH = "/api/files/storage/"
+
+const TOKEN_DURATION = 15 * time.Minute
+
+var ErrNotFound = aws_s3.ErrNotFound
+var ErrUploadNotFound = errors.New("la subida no existe en el almacenamiento")
+
+var settingsData = settings.GetSettings()
+
+// Storage is the backend holding the bytes of the files. Keys are
+// shared by every implementation, so documents stay valid when the
+// backend is swapped
+type Storage interface {
+	UploadFile(file io.Reader, filename, idUser string) (string, string, error)
+	// Store the file at the given key, used for derived files
+	PutFile(key string, file io.Reader) (string, error)
+	GetFile(key string) (io.ReadCloser, error)
+	// Read length bytes of the file from offset
+	GetFileRange(key string, offset, length int64) (io.ReadCloser, error)
+	GetFileToken(key string) (string, error)
+	// Signed URL to upload the file directly with a PUT
+	GetUploadToken(key, contentType string, size int64) (string, error)
+	// Size and content type of the stored file
+	HeadFile(key string) (int64, string, error)
+	// Copy the file to another key, returns the location of the copy
+	CopyFile(src, dst string) (string, error)
+	DeleteFile(key string) error
+	// Multipart uploads, parts are numbered from 1 and completed
+	// with their etags in order
+	CreateMultipartUpload(filename, idUser string) (string, string, error)
+	UploadPart(key, uploadID string, partNumber int, part io.ReadSeeker) (string, error)
+	CompleteMultipartUpload(key, uploadID string, etags []string) (string, error)
+	AbortMultipartUpload(key, uploadID string) error
+}
+
+func sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(settingsData.JWT_SECRET_KEY))
+	mac.Write([]byte(fmt.Sprintf("%s:%d", key, expires)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Signed URL pointing to SERVE_PATH, used by local and memory storages
+func signedURL(key string) string {
+	expires := time.Now().Add(TOKEN_DURATION).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", sign(key, expires))
+
+	return fmt.Sprintf("%s%s%s?%s", settingsData.STORAGE_URL, SERVE_PATH, key, query.Encode())
+}
+
+// Signed PUT URL pointing to SERVE_PATH, the type and size are signed
+func signedUploadURL(key, contentType string, size int64) string {
+	expires := time.Now().Add(TOKEN_DURATION).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("size", strconv.FormatInt(size, 10))
+	query.Set("signature", sign(uploadSignedKey(key, contentType, size), expires))
+
+	return fmt.Sprintf("%s%s%s?%s", settingsData.STORAGE_URL, SERVE_PATH, key, query.Encode())
+}
+
+func uploadSignedKey(key, contentType string, size int64) string {
+	return fmt.Sprintf("PUT:%s:%s:%d", key, contentType, size)
+}
+
+func ContentType(key string) string {
+	contentType := utils.GetMimeType(filepath.Ext(key))
+	if contentType == "" {
+		return "application/octet-stream"
+	}
+	return contentType
+}
+
+func partETag(part []byte) string {
+	sum := md5.Sum(part)
+	return hex.EncodeToString(sum[:])
+}
+
+func VerifySignature(key, expires, signature string) bool {
+	expiresInt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return false
+	}
+	if time.Now().Unix() > expiresInt {
+		return false
+	}
+	return hmac.Equal([]byte(sign(key, expiresInt)), []byte(signature))
+}
+
+func VerifyUploadSignature(key, contentType string, size int64, expires, signature string) bool {
+	return VerifySignature(uploadSignedKey(key, contentType, size), expires, signature)
+}
+
+func NewStorage() Storage {
+	switch settingsData.STORAGE_DRIVER {
+	case LOCAL_DRIVER:
+		return NewLocalStorage(settingsData.STORAGE_PATH)
+	case MEMORY_DRIVER:
+		return NewMemoryStorage()
+	case S3_DRIVER, "":
+		return aws_s3.NewAWSS3()
+	default:
+		panic(fmt.Sprintf("unknown storage driver %s", settingsData.STORAGE_DRIVER))
+	}
+}
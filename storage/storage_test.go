@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testStorages(t *testing.T) map[string]Storage {
+	return map[string]Storage{
+		LOCAL_DRIVER:  NewLocalStorage(t.TempDir()),
+		MEMORY_DRIVER: NewMemoryStorage(),
+	}
+}
+
+func readFile(t *testing.T, storage Storage, key string) []byte {
+	t.Helper()
+	body, err := storage.GetFile(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestStorage(t *testing.T) {
+	content := []byte("%PDF-1.7\ncontenido del archivo")
+	key := "files/user/guia.pdf"
+	copyKey := "files/user/copia.pdf"
+
+	for driver, storage := range testStorages(t) {
+		t.Run(driver, func(t *testing.T) {
+			location, err := storage.PutFile(key, bytes.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(location, SERVE_PATH+key) {
+				t.Fatalf("unexpected location %s", location)
+			}
+
+			if data := readFile(t, storage, key); !bytes.Equal(data, content) {
+				t.Fatalf("expected %q, got %q", content, data)
+			}
+			size, contentType, err := storage.HeadFile(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != int64(len(content)) || contentType != "application/pdf" {
+				t.Fatalf("unexpected head %d %s", size, contentType)
+			}
+			body, err := storage.GetFileRange(key, 1, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(body)
+			body.Close()
+			if err != nil || string(data) != "PDF" {
+				t.Fatalf("expected range %q, got %q", "PDF", data)
+			}
+
+			location, err = storage.CopyFile(key, copyKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(location, SERVE_PATH+copyKey) {
+				t.Fatalf("unexpected location %s", location)
+			}
+			if data := readFile(t, storage, copyKey); !bytes.Equal(data, content) {
+				t.Fatalf("expected copy %q, got %q", content, data)
+			}
+
+			if err := storage.DeleteFile(key); err != nil {
+				t.Fatal(err)
+			}
+			// Deleting a missing file is not an error
+			if err := storage.DeleteFile(key); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := storage.GetFile(copyKey); err != nil {
+				t.Fatalf("the copy was deleted with the original: %v", err)
+			}
+		})
+	}
+}
+
+func TestStorageNotFound(t *testing.T) {
+	key := "files/user/no_existe.pdf"
+
+	for driver, storage := range testStorages(t) {
+		t.Run(driver, func(t *testing.T) {
+			tests := []struct {
+				name string
+				call func() error
+			}{
+				{name: "get", call: func() error {
+					_, err := storage.GetFile(key)
+					return err
+				}},
+				{name: "range", call: func() error {
+					_, err := storage.GetFileRange(key, 0, 1)
+					return err
+				}},
+				{name: "head", call: func() error {
+					_, _, err := storage.HeadFile(key)
+					return err
+				}},
+				{name: "token", call: func() error {
+					_, err := storage.GetFileToken(key)
+					return err
+				}},
+				{name: "copy", call: func() error {
+					_, err := storage.CopyFile(key, "files/user/copia.pdf")
+					return err
+				}},
+			}
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					if err := test.call(); !errors.Is(err, ErrNotFound) {
+						t.Fatalf("expected ErrNotFound, got %v", err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSignedURL(t *testing.T) {
+	key := "files/user/guia.pdf"
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+
+	for driver, storage := range testStorages(t) {
+		t.Run(driver, func(t *testing.T) {
+			if _, err := storage.PutFile(key, strings.NewReader("guia")); err != nil {
+				t.Fatal(err)
+			}
+			token, err := storage.GetFileToken(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signed, err := url.Parse(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signed.Path != SERVE_PATH+key {
+				t.Fatalf("unexpected path %s", signed.Path)
+			}
+			expires := signed.Query().Get("expires")
+			signature := signed.Query().Get("signature")
+
+			tests := []struct {
+				name      string
+				key       string
+				expires   string
+				signature string
+				valid     bool
+			}{
+				{name: "valid", key: key, expires: expires, signature: signature, valid: true},
+				{name: "other key", key: "files/user/otro.pdf", expires: expires, signature: signature},
+				{name: "other expiration", key: key, expires: expired, signature: signature},
+				{name: "expired", key: key, expires: expired, signature: sign(key, time.Now().Add(-time.Minute).Unix())},
+				{name: "bad expiration", key: key, expires: "nunca", signature: signature},
+				{name: "bad signature", key: key, expires: expires, signature: "firma"},
+			}
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					if VerifySignature(test.key, test.expires, test.signature) != test.valid {
+						t.Fatalf("expected valid %v", test.valid)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSignedUploadURL(t *testing.T) {
+	key := "staging/user/guia.pdf"
+
+	for driver, storage := range testStorages(t) {
+		t.Run(driver, func(t *testing.T) {
+			token, err := storage.GetUploadToken(key, "application/pdf", 1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signed, err := url.Parse(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expires := signed.Query().Get("expires")
+			signature := signed.Query().Get("signature")
+			if signed.Query().Get("size") != "1024" {
+				t.Fatalf("unexpected size %s", signed.Query().Get("size"))
+			}
+
+			tests := []struct {
+				name        string
+				contentType string
+				size        int64
+				valid       bool
+			}{
+				{name: "valid", contentType: "application/pdf", size: 1024, valid: true},
+				{name: "other type", contentType: "text/html", size: 1024},
+				{name: "other size", contentType: "application/pdf", size: 1025},
+			}
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					valid := VerifyUploadSignature(key, test.contentType, test.size, expires, signature)
+					if valid != test.valid {
+						t.Fatalf("expected valid %v", test.valid)
+					}
+				})
+			}
+			// A download signature is not valid for uploads
+			if VerifySignature(key, expires, signature) {
+				t.Fatal("the upload signature is valid for downloads")
+			}
+		})
+	}
+}
+
+func TestRangeReader(t *testing.T) {
+	content := []byte("0123456789")
+	key := "files/user/numeros.txt"
+
+	for driver, storage := range testStorages(t) {
+		t.Run(driver, func(t *testing.T) {
+			if _, err := storage.PutFile(key, bytes.NewReader(content)); err != nil {
+				t.Fatal(err)
+			}
+			tests := []struct {
+				name     string
+				offset   int64
+				whence   int
+				position int64
+				expected string
+			}{
+				{name: "start", offset: 0, whence: io.SeekStart, position: 0, expected: "0123456789"},
+				{name: "middle", offset: 4, whence: io.SeekStart, position: 4, expected: "456789"},
+				{name: "from end", offset: -3, whence: io.SeekEnd, position: 7, expected: "789"},
+				{name: "end", offset: 0, whence: io.SeekEnd, position: 10, expected: ""},
+			}
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					reader := NewRangeReader(storage, key, int64(len(content)))
+					defer reader.Close()
+					position, err := reader.Seek(test.offset, test.whence)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if position != test.position {
+						t.Fatalf("expected position %d, got %d", test.position, position)
+					}
+					data, err := io.ReadAll(reader)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if string(data) != test.expected {
+						t.Fatalf("expected %q, got %q", test.expected, data)
+					}
+				})
+			}
+
+			// Seeking after a read requests the file again
+			reader := NewRangeReader(storage, key, int64(len(content)))
+			defer reader.Close()
+			head := make([]byte, 2)
+			if _, err := io.ReadFull(reader, head); err != nil || string(head) != "01" {
+				t.Fatalf("unexpected read %q %v", head, err)
+			}
+			if position, err := reader.Seek(3, io.SeekCurrent); err != nil || position != 5 {
+				t.Fatalf("unexpected seek %d %v", position, err)
+			}
+			if _, err := io.ReadFull(reader, head); err != nil || string(head) != "56" {
+				t.Fatalf("unexpected read %q %v", head, err)
+			}
+			if _, err := reader.Seek(-1, io.SeekStart); err == nil {
+				t.Fatal("expected an error seeking before the start")
+			}
+		})
+	}
+}
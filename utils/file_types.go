@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// Rule with extensions (".pdf") and MIME types ("application/pdf",
+// "image/*"). A type is rejected if it is denied or if there is
+// an allow list and the type is not in it
+type FileTypesRule struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// The default rule, the rule of the user type and the rule of the
+// channel must all accept the type
+type FileTypesPolicy struct {
+	Default   *FileTypesRule            `json:"default"`
+	UserTypes map[string]*FileTypesRule `json:"user_types"`
+	Channels  map[string]*FileTypesRule `json:"channels"`
+}
+
+func (r *FileTypesRule) matches(patterns []string, ext, mimeType string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.HasPrefix(pattern, ".") {
+			if pattern == ext {
+				return true
+			}
+		} else if strings.HasSuffix(pattern, "/*") {
+			if strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *FileTypesRule) check(ext, mimeType string) error {
+	name := ext
+	if name == "" {
+		name = mimeType
+	}
+	denied := r.matches(r.Deny, ext, mimeType)
+	if !denied && (len(r.Allow) == 0 || r.matches(r.Allow, ext, mimeType)) {
+		return nil
+	}
+	if len(r.Allow) == 0 {
+		return fmt.Errorf("el tipo de archivo %s no está permitido", name)
+	}
+	return fmt.Errorf(
+		"el tipo de archivo %s no está permitido, tipos permitidos: %s",
+		name,
+		strings.Join(r.Allow, ", "),
+	)
+}
+
+// Check the type against the rules of the policy, the mime type
+// can have parameters
+func (p *FileTypesPolicy) Check(filename, mimeType, userType, channel string) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = mediaType
+	}
+	rules := []*FileTypesRule{p.Default}
+	if userType != "" {
+		rules = append(rules, p.UserTypes[userType])
+	}
+	rules = append(rules, p.Channels[channel])
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		if err := rule.check(ext, mimeType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Policy used without policy file, executables are denied
+func DefaultFileTypesPolicy() *FileTypesPolicy {
+	return &FileTypesPolicy{
+		Default: &FileTypesRule{
+			Deny: []string{
+				".exe",
+				".msi",
+				".bat",
+				".cmd",
+				".com",
+				".scr",
+				".ps1",
+				".vbs",
+				".sh",
+				".jar",
+				".dll",
+				"application/x-msdownload",
+				"application/x-executable",
+				"application/x-sh",
+			},
+		},
+	}
+}
+
+func ParseFileTypesPolicy(data []byte) (*FileTypesPolicy, error) {
+	var policy *FileTypesPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, errors.New("the policy is empty")
+	}
+	return policy, nil
+}
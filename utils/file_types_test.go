@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseFileTypesPolicy(t *testing.T) {
+	data, err := os.ReadFile("testdata/file_types_policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := ParseFileTypesPolicy(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Default == nil || len(policy.Default.Allow) != 6 || len(policy.Default.Deny) != 2 {
+		t.Fatalf("unexpected default rule %+v", policy.Default)
+	}
+	if policy.UserTypes["a"] == nil || policy.UserTypes["d"] == nil || policy.Channels["upload_image"] == nil {
+		t.Fatalf("unexpected rules %+v %+v", policy.UserTypes, policy.Channels)
+	}
+
+	invalid := []string{
+		"",
+		"null",
+		"[]",
+		`{"default": {"allow": ".pdf"}}`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseFileTypesPolicy([]byte(data)); err == nil {
+			t.Fatalf("expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestFileTypesPolicyCheck(t *testing.T) {
+	data, err := os.ReadFile("testdata/file_types_policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := ParseFileTypesPolicy(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		policy   *FileTypesPolicy
+		filename string
+		mimeType string
+		userType string
+		channel  string
+		rejected bool
+		// Allowed types named in the error
+		allowed string
+	}{
+		{name: "default allows", policy: policy, filename: "guia.pdf", mimeType: "application/pdf", channel: "http"},
+		{name: "default allows mime pattern", policy: policy, filename: "foto.webp", mimeType: "image/webp", channel: "http"},
+		{name: "extension case", policy: policy, filename: "GUIA.PDF", mimeType: "application/pdf", channel: "http"},
+		{name: "mime parameters", policy: policy, filename: "notas.txt", mimeType: "text/plain; charset=utf-8", channel: "http"},
+		{
+			name:     "default denies over allow",
+			policy:   policy,
+			filename: "foto.exe",
+			mimeType: "image/png",
+			channel:  "http",
+			rejected: true,
+			allowed:  ".pdf, .docx, .png, .jpg, .txt, image/*",
+		},
+		{
+			name:     "default does not allow",
+			policy:   policy,
+			filename: "datos.csv",
+			mimeType: "text/csv",
+			channel:  "http",
+			rejected: true,
+			allowed:  ".pdf, .docx, .png, .jpg, .txt, image/*",
+		},
+		{
+			name:     "user type narrows default",
+			policy:   policy,
+			filename: "guia.docx",
+			mimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			userType: "a",
+			channel:  "http",
+			rejected: true,
+			allowed:  ".pdf, .png, .jpg",
+		},
+		{
+			name:     "user type can not widen default",
+			policy:   policy,
+			filename: "datos.csv",
+			mimeType: "text/csv",
+			userType: "a",
+			channel:  "http",
+			rejected: true,
+			allowed:  ".pdf, .docx, .png, .jpg, .txt, image/*",
+		},
+		{
+			name:     "user type deny",
+			policy:   policy,
+			filename: "guia.docx",
+			mimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			userType: "d",
+			channel:  "http",
+			rejected: true,
+		},
+		{
+			name:     "user type without rule",
+			policy:   policy,
+			filename: "guia.docx",
+			mimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			userType: "f",
+			channel:  "http",
+		},
+		{
+			name:     "channel narrows user type",
+			policy:   policy,
+			filename: "guia.pdf",
+			mimeType: "application/pdf",
+			userType: "a",
+			channel:  "upload_image",
+			rejected: true,
+			allowed:  "image/*",
+		},
+		{name: "channel allows", policy: policy, filename: "foto.png", mimeType: "image/png", userType: "a", channel: "upload_image"},
+		{
+			name:     "default policy denies executables",
+			policy:   DefaultFileTypesPolicy(),
+			filename: "programa.exe",
+			mimeType: "application/octet-stream",
+			channel:  "http",
+			rejected: true,
+		},
+		{
+			name:     "default policy denies executable types",
+			policy:   DefaultFileTypesPolicy(),
+			filename: "programa",
+			mimeType: "application/x-msdownload",
+			channel:  "http",
+			rejected: true,
+		},
+		{name: "default policy allows", policy: DefaultFileTypesPolicy(), filename: "datos.csv", mimeType: "text/csv", channel: "http"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.policy.Check(test.filename, test.mimeType, test.userType, test.channel)
+			if !test.rejected {
+				if err != nil {
+					t.Fatalf("expected the type to be accepted, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected the type to be rejected")
+			}
+			if test.allowed != "" && !strings.HasSuffix(err.Error(), "tipos permitidos: "+test.allowed) {
+				t.Fatalf("expected the allowed types %q in %q", test.allowed, err)
+			}
+		})
+	}
+}
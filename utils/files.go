@@ -1,5 +1,7 @@
 package utils
 
+import "mime"
+
 func IsCodeFile(typeFile string) bool {
 	if typeFile == ".py" || typeFile == ".js" || typeFile == ".html" || typeFile == "css" || typeFile == ".c" {
 		return true
@@ -23,3 +25,10 @@ func GetCodeFileMime(typeFile string) string {
 		return ""
 	}
 }
+
+func GetMimeType(ext string) string {
+	if IsCodeFile(ext) {
+		return GetCodeFileMime(ext)
+	}
+	return mime.TypeByExtension(ext)
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Bigger images are not decoded, they would take too much memory
+const MAX_IMAGE_PIXELS = 50000000
+
+const JPEG_QUALITY = 80
+
+var ErrImageTooLarge = errors.New("la imagen es demasiado grande para procesarla")
+
+func IsResizableImage(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	default:
+		return false
+	}
+}
+
+func DecodeImage(file io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > MAX_IMAGE_PIXELS {
+		return nil, ErrImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// Scale the image to fit in a square of maxSize, keeping the
+// aspect ratio. Smaller images are returned as they are
+func ResizeImage(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+	if width > height {
+		height = height * maxSize / width
+		width = maxSize
+	} else {
+		width = width * maxSize / height
+		height = maxSize
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+func isOpaque(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return opaque.Opaque()
+	}
+	return false
+}
+
+// Opaque images are encoded as JPEG and the others as PNG to keep
+// the transparency. Returns the extension of the format
+func EncodeImage(img image.Image) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if isOpaque(img) {
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: JPEG_QUALITY})
+		return buf.Bytes(), ".jpg", err
+	}
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), ".png", err
+}
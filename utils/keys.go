@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+func GetExtension(filename string) string {
+	ext := strings.Split(filename, ".")
+	return ext[len(ext)-1]
+}
+
+func NewFileKey(idUser, filename string) string {
+	fileName := uuid.New()
+	return fmt.Sprintf("user_files/%s/%s.%s", idUser, fileName.String(), GetExtension(filename))
+}
+
+// Key of a direct upload until it is confirmed
+func NewStagingKey(idUser, filename string) string {
+	fileName := uuid.New()
+	return fmt.Sprintf("staging/%s/%s.%s", idUser, fileName.String(), GetExtension(filename))
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrFileTooLarge = errors.New("file too large")
+
+// Limits for the uploads of a route, set by the middlewares
+type FileLimits struct {
+	MaxSize    int64
+	MaxSizeStr string
+	MaxFiles   int
+}
+
+// LimitedReader fails with ErrFileTooLarge as soon as more than
+// Max bytes are read, so the size is enforced while streaming
+type LimitedReader struct {
+	reader io.Reader
+	max    int64
+	read   int64
+}
+
+func (l *LimitedReader) Read(p []byte) (int, error) {
+	n, err := l.reader.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
+
+func (l *LimitedReader) Exceeded() bool {
+	return l.read > l.max
+}
+
+func (l *LimitedReader) Size() int64 {
+	return l.read
+}
+
+func NewLimitedReader(reader io.Reader, max int64) *LimitedReader {
+	return &LimitedReader{
+		reader: reader,
+		max:    max,
+	}
+}
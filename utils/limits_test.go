@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLimitedReader(t *testing.T) {
+	const max = 1024
+
+	tests := []struct {
+		name     string
+		size     int
+		err      error
+		exceeded bool
+	}{
+		{name: "empty", size: 0},
+		{name: "under max", size: max - 1},
+		{name: "max", size: max},
+		{name: "max+1", size: max + 1, err: ErrFileTooLarge, exceeded: true},
+		{name: "over max", size: max * 4, err: ErrFileTooLarge, exceeded: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Reading byte by byte checks the limit on every read
+			readers := map[string]io.Reader{
+				"whole":        bytes.NewReader(make([]byte, test.size)),
+				"byte by byte": iotest.OneByteReader(bytes.NewReader(make([]byte, test.size))),
+			}
+			for name, reader := range readers {
+				t.Run(name, func(t *testing.T) {
+					limited := NewLimitedReader(reader, max)
+					_, err := io.Copy(io.Discard, limited)
+					if !errors.Is(err, test.err) {
+						t.Fatalf("expected error %v, got %v", test.err, err)
+					}
+					if limited.Exceeded() != test.exceeded {
+						t.Fatalf("expected exceeded %v", test.exceeded)
+					}
+					if !test.exceeded && limited.Size() != int64(test.size) {
+						t.Fatalf("expected size %d, got %d", test.size, limited.Size())
+					}
+					if test.exceeded && limited.Size() <= max {
+						t.Fatalf("expected size over %d, got %d", max, limited.Size())
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Bytes read by http.DetectContentType
+const SNIFF_SIZE = 512
+
+var ErrMimeMismatch = errors.New("el contenido del archivo no coincide con su extensión")
+
+// Containers of the office documents
+var zipExtensions = map[string]bool{
+	".zip":  true,
+	".docx": true,
+	".xlsx": true,
+	".pptx": true,
+	".odt":  true,
+	".ods":  true,
+	".odp":  true,
+	".epub": true,
+	".jar":  true,
+}
+
+// Signatures of formats http.DetectContentType does not know, the
+// content sniffed as application/octet-stream must start with one
+var binarySignatures = map[string][][]byte{
+	// Compound files of the old office formats
+	".doc": {{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}},
+	".xls": {{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}},
+	".ppt": {{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}},
+	// Frames without ID3 tag
+	".mp3": {{0xFF, 0xFB}, {0xFF, 0xF3}, {0xFF, 0xF2}},
+	".7z":  {[]byte("7z\xBC\xAF\x27\x1C")},
+	".psd": {[]byte("8BPS")},
+}
+
+// Content the sniffer could not identify, accepted for text
+// formats, which have no magic bytes, and for the formats with a
+// signature of binarySignatures
+func acceptsUnknown(ext, extMime string, head []byte) bool {
+	if isTextMime(ext, extMime) {
+		return true
+	}
+	for _, signature := range binarySignatures[ext] {
+		if bytes.HasPrefix(head, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+func baseMime(mimeType string) string {
+	return strings.TrimSpace(strings.Split(mimeType, ";")[0])
+}
+
+func mimeFamily(mimeType string) string {
+	return strings.Split(mimeType, "/")[0]
+}
+
+func isTextMime(ext, mimeType string) bool {
+	if IsCodeFile(ext) || strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// ResolveMimeType compares the type of the extension with the type
+// sniffed from the first bytes of the content and returns the type
+// to store, or ErrMimeMismatch when they do not agree
+func ResolveMimeType(ext string, head []byte) (string, error) {
+	ext = strings.ToLower(ext)
+	extMime := baseMime(GetMimeType(ext))
+	sniffed := baseMime(http.DetectContentType(head))
+
+	if extMime == "" {
+		return sniffed, nil
+	}
+	if sniffed == extMime {
+		return extMime, nil
+	}
+	if sniffed == "application/octet-stream" {
+		if acceptsUnknown(ext, extMime, head) {
+			return extMime, nil
+		}
+		return "", ErrMimeMismatch
+	}
+	if strings.HasPrefix(sniffed, "text/") && isTextMime(ext, extMime) {
+		return extMime, nil
+	}
+	if sniffed == "application/zip" && zipExtensions[ext] {
+		return extMime, nil
+	}
+	// Other formats of the same media, ej. a jpeg named .png
+	switch mimeFamily(extMime) {
+	case "image", "audio", "video":
+		if mimeFamily(sniffed) == mimeFamily(extMime) {
+			return sniffed, nil
+		}
+	}
+	return "", ErrMimeMismatch
+}
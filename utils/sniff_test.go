@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveMimeType(t *testing.T) {
+	executable := []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff\x00\x00")
+	ole := []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1\x00\x00\x00\x00")
+
+	tests := []struct {
+		name string
+		ext  string
+		head []byte
+		mime string
+		err  error
+	}{
+		{name: "pdf", ext: ".pdf", head: []byte("%PDF-1.7\n"), mime: "application/pdf"},
+		{name: "png", ext: ".png", head: []byte("\x89PNG\r\n\x1a\n"), mime: "image/png"},
+		{name: "jpeg named png", ext: ".png", head: []byte("\xFF\xD8\xFF\xE0"), mime: "image/jpeg"},
+		{name: "docx", ext: ".docx", head: []byte("PK\x03\x04"), mime: GetMimeType(".docx")},
+		{name: "csv", ext: ".csv", head: []byte("a,b\n1,2\n"), mime: "text/csv"},
+		{name: "binary csv", ext: ".csv", head: []byte("a,b\x00\x01"), mime: "text/csv"},
+		{name: "doc", ext: ".doc", head: ole, mime: GetMimeType(".doc")},
+		{name: "executable as pdf", ext: ".pdf", head: executable, err: ErrMimeMismatch},
+		{name: "executable as docx", ext: ".docx", head: executable, err: ErrMimeMismatch},
+		{name: "executable as png", ext: ".png", head: executable, err: ErrMimeMismatch},
+		{name: "executable as doc", ext: ".doc", head: executable, err: ErrMimeMismatch},
+		{name: "html as pdf", ext: ".pdf", head: []byte("<html><script>"), err: ErrMimeMismatch},
+		{name: "pdf as jpg", ext: ".jpg", head: []byte("%PDF-1.7\n"), err: ErrMimeMismatch},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Office and csv types come from the mime.types of the system
+			if GetMimeType(test.ext) == "" {
+				t.Skipf("no type for %s in this system", test.ext)
+			}
+			mimeType, err := ResolveMimeType(test.ext, test.head)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if mimeType != test.mime {
+				t.Fatalf("expected type %q, got %q", test.mime, mimeType)
+			}
+		})
+	}
+}
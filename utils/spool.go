@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"io"
+	"os"
+)
+
+// SpoolFile copies up to max bytes of the reader to a temporary file,
+// so several files of one body can be read at the same time. The
+// caller must close and remove the file
+func SpoolFile(reader io.Reader, max int64) (*os.File, error) {
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(tmp, io.LimitReader(reader, max))
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		RemoveSpooled(tmp)
+		return nil, err
+	}
+	return tmp, nil
+}
+
+func RemoveSpooled(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
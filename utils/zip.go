@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Names are chosen by the users, a name can not leave the zip
+// nor create other folders
+func ZipSegment(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// Path of a file inside the zip, the folder is empty for the root
+func ZipPath(folder, filename string) string {
+	return path.Join(folder, ZipSegment(filename))
+}
+
+// Repeated names get a number, "Guia (2).pdf". The reserved names
+// are already used in the zip
+func UniqueZipNames(names []string, reserved ...string) []string {
+	used := make(map[string]bool)
+	for _, name := range reserved {
+		used[name] = true
+	}
+	unique := make([]string, len(names))
+	for i, name := range names {
+		ext := path.Ext(name)
+		// Names like ".env" have no extension
+		if ext == path.Base(name) {
+			ext = ""
+		}
+		unique[i] = name
+		for n := 2; used[unique[i]]; n++ {
+			unique[i] = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+		}
+		used[unique[i]] = true
+	}
+	return unique
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestZipSegment(t *testing.T) {
+	tests := map[string]string{
+		"Guia.pdf":         "Guia.pdf",
+		"":                 "_",
+		".":                "_",
+		"..":               "_",
+		"...":              "...",
+		"../../etc/passwd": ".._.._etc_passwd",
+		"/absoluto.pdf":    "_absoluto.pdf",
+		"..\\windows.ini":  ".._windows.ini",
+		"año 2023.pdf":     "año 2023.pdf",
+	}
+	for name, expected := range tests {
+		if segment := ZipSegment(name); segment != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, name, segment)
+		}
+	}
+}
+
+func TestZipPath(t *testing.T) {
+	tests := []struct {
+		folder   string
+		filename string
+		expected string
+	}{
+		// Files of the root have no leading slash
+		{folder: "", filename: "Guia.pdf", expected: "Guia.pdf"},
+		{folder: "", filename: "/Guia.pdf", expected: "_Guia.pdf"},
+		{folder: "", filename: "..", expected: "_"},
+		{folder: "Clases", filename: "Guia.pdf", expected: "Clases/Guia.pdf"},
+		{folder: "Clases/2023", filename: "Guia.pdf", expected: "Clases/2023/Guia.pdf"},
+		{folder: "Clases", filename: "../Guia.pdf", expected: "Clases/.._Guia.pdf"},
+	}
+	for _, test := range tests {
+		if zipPath := ZipPath(test.folder, test.filename); zipPath != test.expected {
+			t.Fatalf("expected %q for %q in %q, got %q", test.expected, test.filename, test.folder, zipPath)
+		}
+	}
+}
+
+func TestUniqueZipNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    []string
+		reserved []string
+		expected []string
+	}{
+		{
+			name:     "unique",
+			names:    []string{"Guia.pdf", "Clases/Guia.pdf"},
+			expected: []string{"Guia.pdf", "Clases/Guia.pdf"},
+		},
+		{
+			name:     "repeated",
+			names:    []string{"Guia.pdf", "Guia.pdf", "Guia.pdf"},
+			expected: []string{"Guia.pdf", "Guia (2).pdf", "Guia (3).pdf"},
+		},
+		{
+			name:     "numbered name taken",
+			names:    []string{"Guia.pdf", "Guia (2).pdf", "Guia.pdf"},
+			expected: []string{"Guia.pdf", "Guia (2).pdf", "Guia (3).pdf"},
+		},
+		{
+			name:     "in folder",
+			names:    []string{"Clases/Guia.pdf", "Clases/Guia.pdf"},
+			expected: []string{"Clases/Guia.pdf", "Clases/Guia (2).pdf"},
+		},
+		{
+			name:     "without extension",
+			names:    []string{"LEEME", "LEEME", ".env", ".env", "v1.0/notas"},
+			expected: []string{"LEEME", "LEEME (2)", ".env", ".env (2)", "v1.0/notas"},
+		},
+		{
+			name:     "reserved",
+			names:    []string{"archivos_omitidos.txt"},
+			reserved: []string{"archivos_omitidos.txt"},
+			expected: []string{"archivos_omitidos (2).txt"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unique := UniqueZipNames(test.names, test.reserved...)
+			if !reflect.DeepEqual(unique, test.expected) {
+				t.Fatalf("expected %q, got %q", test.expected, unique)
+			}
+		})
+	}
+}